@@ -0,0 +1,121 @@
+//go:build client || all
+// +build client all
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+	"github.com/OpenCHAMI/configurator/pkg/state"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Generate and atomically write files, then run hooks for changed targets",
+	Long: "Generate files like 'generate' and write each file that changed to its output\n" +
+		"path atomically using the mode, owner, and group set for the target. The hooks\n" +
+		"set for a target are ran after writing only if at least one of its files changed\n" +
+		"and are ran again by the next apply until they succeed.\n\n" +
+		"The applied files are kept in the state directory to roll back with 'rollback'.",
+	Run: func(cmd *cobra.Command, args []string) {
+		prepareGenerate(cmd)
+		defer logCacheStats()
+
+		// generate the files the same way as 'generate' before writing them
+		var results []targetOutput
+		if len(targets) > 0 {
+			var err error
+			results, err = generateTargets(context.Background(), &conf, targets...)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to generate config")
+				os.Exit(1)
+			}
+		} else {
+			outputBytes, err := generateWithPlugin(&conf)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to generate files")
+				os.Exit(1)
+			}
+			results = append(results, targetOutput{outputs: outputBytes})
+		}
+
+		store := state.NewStore(conf.State.Path, conf.State.Keep)
+		for _, result := range results {
+			err := applyTarget(store, result, len(results))
+			if err != nil {
+				log.Error().Err(err).Str("target", result.target).Msg("failed to apply target")
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// Writes the files generated for a target, keeps them in the state directory
+// if any changed, and then runs the target's hooks.
+func applyTarget(store *state.Store, result targetOutput, targetCount int) error {
+	var (
+		target = conf.Targets[result.target]
+		files  = outputFiles(result, targetCount)
+	)
+
+	// files generated without a target have no hooks or state to keep
+	if result.target == "" {
+		_, err := state.WriteFiles(target, files)
+		if err != nil {
+			return fmt.Errorf("failed to apply files: %w", err)
+		}
+		return nil
+	}
+
+	meta := state.Meta{
+		Target:        result.target,
+		InventoryHash: result.inputHash,
+	}
+	if gen, err := generator.FindGenerator(&conf, result.target); err == nil {
+		meta.GeneratorVersion = gen.GetVersion()
+	}
+	changed, err := store.Apply(meta, target, files)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		log.Info().Str("target", result.target).Msg("no changes to apply")
+	}
+	return nil
+}
+
+// Returns the generated files keyed by the absolute path that they are
+// written to.
+func outputFiles(result targetOutput, targetCount int) map[string][]byte {
+	files := make(map[string][]byte, len(result.outputs))
+	for source, path := range outputFilePaths(result, targetCount) {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		files[path] = result.outputs[source]
+	}
+	return files
+}
+
+func init() {
+	applyCmd.Flags().StringSliceVar(&targets, "target", []string{}, "set the targets to run pre-defined conf")
+	applyCmd.Flags().StringSliceVar(&templatePaths, "template", []string{}, "set the paths for the Jinja 2 templates to use")
+	applyCmd.Flags().StringVar(&pluginPath, "plugin", "", "set the generator plugin path")
+	applyCmd.Flags().StringVarP(&outputPath, "output", "o", "", "set the output path to write files to")
+	applyCmd.Flags().StringVar(&remoteHost, "host", "", "set the SMD host (overrides 'smd.host' in config)")
+	applyCmd.Flags().StringArrayVar(&setVars, "set", []string{}, "set a variable passed to templates as key=value (overrides 'vars' in config)")
+	applyCmd.Flags().BoolVar(&strictTemplates, "strict", false, "set whether undefined variables in templates are an error (overrides 'strict' in config)")
+
+	applyCmd.MarkFlagRequired("output")
+	applyCmd.MarkFlagsMutuallyExclusive("target", "plugin")
+	applyCmd.MarkFlagsMutuallyExclusive("target", "template")
+	applyCmd.MarkFlagsRequiredTogether("plugin", "template")
+
+	rootCmd.AddCommand(applyCmd)
+}
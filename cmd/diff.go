@@ -0,0 +1,112 @@
+//go:build client || all
+// +build client all
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/OpenCHAMI/configurator/pkg/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show what would change in files on disk without writing them",
+	Long: "Generate files like 'generate' and compare each output with the file already\n" +
+		"at its output path. Unified diffs are printed for each file that would change.\n\n" +
+		"Exits with 0 if there are no changes, 1 if there are changes, and 2 if an error occurs.",
+	Run: func(cmd *cobra.Command, args []string) {
+		prepareGenerate(cmd)
+		defer logCacheStats()
+
+		// generate the files the same way as 'generate' without writing them
+		var results []targetOutput
+		if len(targets) > 0 {
+			var err error
+			results, err = generateTargets(context.Background(), &conf, targets...)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to generate config")
+				os.Exit(2)
+			}
+		} else {
+			outputBytes, err := generateWithPlugin(&conf)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to generate files")
+				os.Exit(2)
+			}
+			results = append(results, targetOutput{outputs: outputBytes})
+		}
+
+		changed := 0
+		for _, result := range results {
+			n, err := diffOutputs(result, len(results))
+			if err != nil {
+				log.Error().Err(err).Str("target", result.target).Msg("failed to diff files")
+				os.Exit(2)
+			}
+			changed += n
+		}
+		if changed > 0 {
+			if verbose {
+				log.Info().Int("files", changed).Msg("found changes")
+			}
+			os.Exit(1)
+		}
+	},
+}
+
+// Prints a unified diff between each generated file and the file found at its
+// output path. Files that do not exist yet are compared as empty. Returns the
+// number of files that would change.
+func diffOutputs(result targetOutput, targetCount int) (int, error) {
+	var (
+		outputBytes = result.outputs
+		paths       = outputFilePaths(result, targetCount)
+		sources     = make([]string, 0, len(paths))
+		changed     = 0
+	)
+
+	// sort so that the diffs are printed in the same order every run
+	for source := range paths {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		path := paths[source]
+		current, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return changed, fmt.Errorf("failed to read file: %w", err)
+		}
+		diff := util.UnifiedDiff(path, path, current, outputBytes[source])
+		if diff != "" {
+			fmt.Print(diff)
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+func init() {
+	diffCmd.Flags().StringSliceVar(&targets, "target", []string{}, "set the targets to run pre-defined conf")
+	diffCmd.Flags().StringSliceVar(&templatePaths, "template", []string{}, "set the paths for the Jinja 2 templates to use")
+	diffCmd.Flags().StringVar(&pluginPath, "plugin", "", "set the generator plugin path")
+	diffCmd.Flags().StringVarP(&outputPath, "output", "o", "", "set the output path of the files to compare with")
+	diffCmd.Flags().StringVar(&remoteHost, "host", "", "set the SMD host (overrides 'smd.host' in config)")
+	diffCmd.Flags().StringArrayVar(&setVars, "set", []string{}, "set a variable passed to templates as key=value (overrides 'vars' in config)")
+	diffCmd.Flags().BoolVar(&strictTemplates, "strict", false, "set whether undefined variables in templates are an error (overrides 'strict' in config)")
+
+	diffCmd.MarkFlagRequired("output")
+	diffCmd.MarkFlagsMutuallyExclusive("target", "plugin")
+	diffCmd.MarkFlagsMutuallyExclusive("target", "template")
+	diffCmd.MarkFlagsRequiredTogether("plugin", "template")
+
+	rootCmd.AddCommand(diffCmd)
+}
@@ -4,24 +4,35 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 
+	"github.com/OpenCHAMI/configurator/pkg/client"
 	"github.com/OpenCHAMI/configurator/pkg/util"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
 
+var fetchArchive bool
+
 var fetchCmd = &cobra.Command{
 	Use:   "fetch",
 	Short: "Fetch a config file from a remote instance of configurator",
-	Long:  "This command is simplified to make a HTTP request to the a configurator service.",
+	Long: "This command is simplified to make a HTTP request to the a configurator service.\n\n" +
+		"The files returned for each target are written to the output directory set with\n" +
+		"'-o/--output' or printed to stdout as JSON if no output is set. Set '--archive'\n" +
+		"to request an archive that keeps the output paths and modes set for the target.",
 	Run: func(cmd *cobra.Command, args []string) {
 		// make sure a host is set
 		if remoteHost == "" {
 			log.Error().Msg("no '--host' argument set")
-			return
+			os.Exit(1)
 		}
 
 		// check if we actually have any targets to run
@@ -30,48 +41,128 @@ var fetchCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		// check to see if an access token is available from env
-		if conf.AccessToken == "" {
-			// check if OCHAMI_ACCESS_TOKEN env var is set if no access token is provided and use that instead
-			accessToken := os.Getenv("ACCESS_TOKEN")
-			if accessToken != "" {
-				conf.AccessToken = accessToken
-			} else {
-				// TODO: try and fetch token first if it is needed
-				if verbose {
-					log.Warn().Msg("No token found. Attempting to generate config without one...")
-				}
-			}
+		// use the token from the flag first, then the config, then the env
+		if !cmd.Flags().Changed("access-token") {
+			accessToken = conf.AccessToken
+		}
+		if accessToken == "" {
+			accessToken = os.Getenv("ACCESS_TOKEN")
+		}
+		if accessToken == "" && verbose {
+			// TODO: try and fetch token first if it is needed
+			log.Warn().Msg("No token found. Attempting to generate config without one...")
 		}
 
-		// add the "Authorization" header if an access token is supplied
-		headers := map[string]string{}
-		if accessToken != "" {
-			headers["Authorization"] = "Bearer " + accessToken
+		// use cert path from cobra if empty
+		if conf.CertPath == "" {
+			conf.CertPath = cacertPath
 		}
+		params, err := client.ToParams(
+			client.WithCertPoolFile(conf.CertPath),
+			client.WithInsecure(insecure),
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to create client")
+			os.Exit(1)
+		}
+		httpClient := params.NewHTTPClient()
 
+		failed := false
 		for _, target := range targets {
-			// make a request for each target
-			url := fmt.Sprintf("%s/generate?target=%s", remoteHost, target)
-			res, body, err := util.MakeRequest(url, http.MethodGet, nil, headers)
+			err := fetchTarget(&httpClient, target)
 			if err != nil {
-				log.Error().Err(err).Msg("failed to fetch files")
-				return
-			}
-			// handle getting other error codes other than a 200
-			if res != nil {
-				// NOTE: the server responses are already marshaled to JSON
-				fmt.Print(string(body))
+				log.Error().Err(err).Str("target", target).Msg("failed to fetch files")
+				failed = true
 			}
 		}
+		if failed {
+			os.Exit(1)
+		}
 	},
 }
 
+// Requests the files for the target from the remote configurator service and
+// writes them to the output path or stdout if no output path is set.
+func fetchTarget(httpClient *http.Client, target string) error {
+	query := url.Values{"target": {target}}
+	if fetchArchive {
+		query.Set("archive", string(util.ArchiveTarGz))
+	}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/generate?%s", strings.TrimSuffix(remoteHost, "/"), query.Encode()), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Add("User-Agent", "configurator")
+	if accessToken != "" {
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %v", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+
+	// handle getting other error codes other than a 200
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("server responded with %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+
+	// extract the archive if the server sent one
+	if format, ok := util.ArchiveFormatFromContentType(res.Header.Get("Content-Type")); ok {
+		if outputPath == "" {
+			_, err = os.Stdout.Write(body)
+			return err
+		}
+		paths, err := util.ExtractArchive(bytes.NewReader(body), format, outputPath)
+		if err != nil {
+			return fmt.Errorf("failed to extract archive: %v", err)
+		}
+		for _, path := range paths {
+			log.Info().Msgf("wrote file to '%s'\n", path)
+		}
+		return nil
+	}
+
+	// NOTE: the server responses are already marshaled to JSON
+	if outputPath == "" {
+		fmt.Print(string(body))
+		return nil
+	}
+	files := map[string]string{}
+	err = json.Unmarshal(body, &files)
+	if err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	err = os.MkdirAll(outputPath, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to make output directory: %v", err)
+	}
+
+	// write the files to the same relative paths as the keys like an archive
+	archiveFiles := make([]util.ArchiveFile, 0, len(files))
+	for key, contents := range files {
+		archiveFiles = append(archiveFiles, util.ArchiveFile{Name: key, Contents: []byte(contents)})
+	}
+	paths, err := util.ExtractFiles(archiveFiles, outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to write files: %v", err)
+	}
+	for _, path := range paths {
+		log.Info().Msgf("wrote file to '%s'\n", path)
+	}
+	return nil
+}
+
 func init() {
 	fetchCmd.Flags().StringVar(&remoteHost, "host", "", "set the remote configurator host and port")
 	fetchCmd.Flags().StringSliceVar(&targets, "target", nil, "set the target configs to make")
-	fetchCmd.Flags().StringVarP(&outputPath, "output", "o", "", "set the output path for config targets")
-	fetchCmd.Flags().StringVar(&accessToken, "access-token", "o", "set the output path for config targets")
+	fetchCmd.Flags().StringVarP(&outputPath, "output", "o", "", "set the output directory for config targets")
+	fetchCmd.Flags().StringVar(&accessToken, "access-token", "", "set the access token (overrides 'access-token' in config and ACCESS_TOKEN)")
+	fetchCmd.Flags().BoolVar(&fetchArchive, "archive", false, "set whether to request an archive to keep output paths and modes")
 
 	rootCmd.AddCommand(fetchCmd)
 }
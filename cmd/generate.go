@@ -4,10 +4,13 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/OpenCHAMI/configurator/pkg/client"
 	"github.com/OpenCHAMI/configurator/pkg/config"
@@ -22,117 +25,258 @@ var (
 	templatePaths     []string
 	pluginPath        string
 	useCompression    bool
+	archiveFormat     string
+	showGraph         bool
+	strictTemplates   bool
+	setVars           []string
+	cliVars           map[string]any
+	inventoryCache    *client.Cache
 )
 
 var generateCmd = &cobra.Command{
 	Use:   "generate",
 	Short: "Generate a config file from state management",
 	Run: func(cmd *cobra.Command, args []string) {
-		// make sure that we have a token present before trying to make request
-		if conf.AccessToken == "" {
-			// check if OCHAMI_ACCESS_TOKEN env var is set if no access token is provided and use that instead
-			accessToken := os.Getenv("ACCESS_TOKEN")
-			if accessToken != "" {
-				conf.AccessToken = accessToken
-			} else {
-				// TODO: try and fetch token first if it is needed
-				if verbose {
-					log.Warn().Msg("No token found. Attempting to generate conf without one...\n")
-				}
-			}
-		}
-
-		// use cert path from cobra if empty
-		if conf.CertPath == "" {
-			conf.CertPath = cacertPath
-		}
-
-		// show conf as JSON and generators if verbose
-		if verbose {
-			b, err := json.MarshalIndent(conf, "", "  ")
+		// show the order that targets will run in without generating
+		if showGraph {
+			graph, err := generator.ResolveTargets(&conf, targets...)
 			if err != nil {
-				log.Error().Err(err).Msg("failed to marshal config")
+				log.Error().Err(err).Msg("failed to resolve targets")
+				os.Exit(1)
 			}
-			// print the config file as JSON
-			fmt.Printf("%v\n", string(b))
+			fmt.Print(graph.DOT())
+			return
 		}
 
+		prepareGenerate(cmd)
+		defer logCacheStats()
+
 		// run all of the target recursively until completion if provided
 		if len(targets) > 0 {
 			RunTargets(&conf, args, targets...)
 		} else {
-			if pluginPath == "" {
-				log.Error().Msg("no plugin path specified")
-				return
+			outputBytes, err := generateWithPlugin(&conf)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to generate files")
+				os.Exit(1)
 			}
 
-			// load the templates to use
-			templates := map[string]generator.Template{}
-			for _, path := range templatePaths {
-				template := generator.Template{}
-				template.LoadFromFile(path)
-				if !template.IsEmpty() {
-					templates[path] = template
-				}
+			// if we have more than one target and output is set, create configs in directory
+			results := []targetOutput{{outputs: outputBytes}}
+			if useCompression {
+				writeArchiveOutput(conf, results)
+				return
 			}
+			writeOutput(results[0], len(targets))
+		}
+	},
+}
 
-			params := generator.Params{
-				Templates: templates,
+// Sets the access token, CA cert, and SMD host in the config from the
+// environment and flags before generating files. This is shared by all of
+// the commands that generate files.
+func prepareGenerate(cmd *cobra.Command) {
+	// make sure that we have a token present before trying to make request
+	if conf.AccessToken == "" {
+		// check if OCHAMI_ACCESS_TOKEN env var is set if no access token is provided and use that instead
+		accessToken := os.Getenv("ACCESS_TOKEN")
+		if accessToken != "" {
+			conf.AccessToken = accessToken
+		} else {
+			// TODO: try and fetch token first if it is needed
+			if verbose {
+				log.Warn().Msg("No token found. Attempting to generate conf without one...\n")
 			}
+		}
+	}
 
-			// set the client options
-			// params.ClientOpts = append(params.ClientOpts, client.WithHost(remoteHost))
-			if conf.AccessToken != "" {
-				params.ClientOpts = append(params.ClientOpts, client.WithAccessToken(conf.AccessToken))
-			}
-			if conf.CertPath != "" {
-				params.ClientOpts = append(params.ClientOpts, client.WithCertPoolFile(conf.CertPath))
-			}
+	// use cert path from cobra if empty
+	if conf.CertPath == "" {
+		conf.CertPath = cacertPath
+	}
 
-			// run generator.Generate() with just plugin path and templates provided
-			outputBytes, err := generator.Generate(&conf, pluginPath, params)
-			if err != nil {
-				log.Error().Err(err).Msg("failed to generate files")
-			}
+	// skip verifying certificates for every client if set with flag
+	if cmd.Flags().Changed("insecure") {
+		conf.SmdClient.Insecure = insecure
+		conf.BssClient.Insecure = insecure
+	}
 
-			// if we have more than one target and output is set, create configs in directory
-			outputMap := generator.ConvertContentsToString(outputBytes)
-			writeOutput(outputBytes, len(targets), len(outputMap))
+	// override the SMD host from config if set with flag
+	if cmd.Flags().Changed("host") {
+		conf.SmdClient.Host = remoteHost
+	}
+
+	// fail on undefined template variables if set with flag
+	if cmd.Flags().Changed("strict") {
+		conf.Strict = strictTemplates
+	}
+
+	// set the template variables that override the ones in the config
+	var err error
+	cliVars, err = parseVars(setVars)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse variables")
+		os.Exit(1)
+	}
+
+	// show conf as JSON and generators if verbose
+	if verbose {
+		b, err := json.MarshalIndent(conf, "", "  ")
+		if err != nil {
+			log.Error().Err(err).Msg("failed to marshal config")
 		}
-	},
+		// print the config file as JSON
+		fmt.Printf("%v\n", string(b))
+	}
+
+	// share fetched inventory between all generators for this run
+	inventoryCache = client.NewCache(0)
+}
+
+// Generate files with the plugin and templates set with the '--plugin' and
+// '--template' flags instead of using a target.
+func generateWithPlugin(conf *config.Config) (generator.FileMap, error) {
+	if pluginPath == "" {
+		return nil, fmt.Errorf("no plugin path specified")
+	}
+
+	// load the templates to use
+	templates := map[string]generator.Template{}
+	for _, path := range templatePaths {
+		template := generator.Template{Strict: conf.Strict}
+		if err := template.LoadFromFile(path); err != nil {
+			return nil, fmt.Errorf("failed to load template '%s': %w", path, err)
+		}
+		if !template.IsEmpty() {
+			templates[path] = template
+		}
+	}
+
+	params := generator.Params{
+		Templates: templates,
+	}
+
+	// set the client options and variables from the config
+	params.ClientOpts = conf.SmdClientOptions()
+	params.BssClientOpts = conf.BssClientOptions()
+	params.Vars = conf.TemplateVars("")
+	generator.WithCache(inventoryCache)(&params)
+	generator.WithVars(cliVars)(&params)
+
+	// run generator.Generate() with just plugin path and templates provided
+	return generator.Generate(conf, pluginPath, params)
+}
+
+// Parses the variables set with '--set key=value' into a map. The values are
+// always strings.
+func parseVars(args []string) (map[string]any, error) {
+	vars := make(map[string]any, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("variable '%s' must be set as key=value", arg)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// The files generated for a single target with a hash of the inventory that
+// was used to generate them. If the target sets where its outputs are written,
+// the files are keyed by their path relative to the output path.
+type targetOutput struct {
+	target    string
+	outputs   generator.FileMap
+	inputHash string
+	inputs    *client.Cache
+	mapped    bool
 }
 
 // Generate files by supplying a list of targets as string values. Currently,
-// targets are defined statically in a config file. Targets are ran recursively
-// if more targets are nested in a defined target, but will not run additional
-// child targets if it is the same as the parent.
-//
-// NOTE: This may be changed in the future how this is done.
+// targets are defined statically in a config file. Any targets nested in a
+// defined target are ran after it, but each target is only ran once and
+// targets that run each other in a cycle are an error.
 func RunTargets(conf *config.Config, args []string, targets ...string) {
-	// generate config with each supplied target
-	for _, target := range targets {
-		outputBytes, err := generator.GenerateWithTarget(conf, target)
+	results, err := generateTargets(context.Background(), conf, targets...)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to generate config")
+		os.Exit(1)
+	}
+
+	// write every file to a single archive if compression is enabled
+	if useCompression {
+		writeArchiveOutput(*conf, results)
+		return
+	}
+
+	// if we have more than one target and output is set, create configs in directory
+	for _, result := range results {
+		writeOutput(result, len(results))
+	}
+}
+
+// Generate files for each target and any other targets that they run without
+// writing them. Targets are resolved into a graph so that each target is only
+// ran once and targets that do not depend on each other are ran in parallel.
+// The outputs are returned in the order that targets can be ran in.
+func generateTargets(ctx context.Context, conf *config.Config, targets ...string) ([]targetOutput, error) {
+	graph, err := generator.ResolveTargets(conf, targets...)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu      sync.Mutex
+		outputs = make(map[string]targetOutput, len(graph.Targets))
+	)
+	err = graph.Run(ctx, func(ctx context.Context, target string) error {
+		// keep track of what the target fetched from the shared cache
+		tracked := inventoryCache.Track()
+		outputBytes, err := generator.GenerateWithTarget(conf, target,
+			generator.WithContext(ctx),
+			generator.WithCache(tracked),
+			generator.WithVars(cliVars),
+		)
 		if err != nil {
-			log.Error().Err(err).Str("target", target).Msg("failed to generate config")
-			os.Exit(1)
+			return fmt.Errorf("failed to generate config with target '%s': %w", target, err)
 		}
+		mu.Lock()
+		defer mu.Unlock()
+		outputs[target] = targetOutput{
+			target:    target,
+			outputs:   outputBytes,
+			inputHash: tracked.Hash(),
+			inputs:    tracked,
+			mapped:    len(conf.Targets[target].Outputs) > 0,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// if we have more than one target and output is set, create configs in directory
-		outputMap := generator.ConvertContentsToString(outputBytes)
-		writeOutput(outputBytes, len(targets), len(outputMap))
-
-		// remove any targets that are the same as current to prevent infinite loop
-		nextTargets := util.CopyIf(conf.Targets[target].RunTargets, func(nextTarget string) bool {
-			return nextTarget != target
-		})
+	results := make([]targetOutput, 0, len(graph.Targets))
+	for _, target := range graph.Targets {
+		results = append(results, outputs[target])
+	}
+	return results, nil
+}
 
-		// ...then, run any other targets that the current target has
-		RunTargets(conf, args, nextTargets...)
+func logCacheStats() {
+	if inventoryCache == nil {
+		return
 	}
+	hits, misses := inventoryCache.Stats()
+	log.Debug().Int("hits", hits).Int("misses", misses).Msg("inventory cache stats")
 }
 
-func writeOutput(outputBytes generator.FileMap, targetCount int, templateCount int) {
-	outputMap := generator.ConvertContentsToString(outputBytes)
+func writeOutput(result targetOutput, targetCount int) {
+	var (
+		outputBytes   = result.outputs
+		outputMap     = generator.ConvertContentsToString(outputBytes)
+		templateCount = len(outputMap)
+	)
 	if outputPath == "" {
 		// write only to stdout by default
 		if len(outputMap) == 1 {
@@ -144,53 +288,118 @@ func writeOutput(outputBytes generator.FileMap, targetCount int, templateCount i
 				fmt.Printf("-- file: %s, size: %d B\n%s\n", path, len(contents), string(contents))
 			}
 		}
-	} else if outputPath != "" && targetCount == 1 && templateCount == 1 {
+	} else if outputPath != "" && !result.mapped && targetCount <= 1 && templateCount == 1 {
 		// write just a single file using provided name
-		for _, contents := range outputBytes {
-			err := os.WriteFile(outputPath, contents, 0o644)
+		for path, contents := range outputBytes {
+			writeOutputFile(outputFilePaths(result, targetCount)[path], contents)
+		}
+	} else if outputPath != "" && (result.mapped || targetCount > 1 || templateCount > 1) {
+		// write multiple files in directory using template name or output path
+		paths := outputFilePaths(result, targetCount)
+		for path, contents := range outputBytes {
+			err := os.MkdirAll(filepath.Dir(paths[path]), 0o755)
 			if err != nil {
-				log.Error().Err(err).Str("path", outputPath).Msg("failed to write config file")
+				log.Error().Err(err).Str("path", filepath.Dir(paths[path])).Msg("failed to make output directory")
 				os.Exit(1)
 			}
-			log.Info().Msgf("wrote file to '%s'\n", outputPath)
-		}
-	} else if outputPath != "" && targetCount > 1 && useCompression {
-		// write multiple files to archive, compress, then save to output path
-		out, err := os.Create(fmt.Sprintf("%s.tar.gz", outputPath))
-		if err != nil {
-			log.Error().Err(err).Str("path", outputPath).Msg("failed to write archive")
-			os.Exit(1)
-		}
-		files := make([]string, len(outputBytes))
-		i := 0
-		for path := range outputBytes {
-			files[i] = path
-			i++
+			writeOutputFile(paths[path], contents)
 		}
-		err = util.CreateArchive(files, out)
+	}
+}
+
+// Writes the files from every target to a single archive at the output path
+// with the extension for the archive format added if missing. The archive is
+// written to stdout if no output path is set.
+func writeArchiveOutput(conf config.Config, results []targetOutput) {
+	format, err := util.ParseArchiveFormat(archiveFormat)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create archive")
+		os.Exit(1)
+	}
+	files, err := archiveFiles(conf, results)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create archive")
+		os.Exit(1)
+	}
+
+	if outputPath == "" {
+		err = util.WriteArchive(os.Stdout, format, files)
 		if err != nil {
-			log.Error().Err(err).Str("path", outputPath).Msg("failed to create archive")
+			log.Error().Err(err).Msg("failed to write archive")
 			os.Exit(1)
 		}
+		return
+	}
+
+	path := outputPath
+	if !strings.HasSuffix(path, format.Extension()) {
+		path += format.Extension()
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("failed to write archive")
+		os.Exit(1)
+	}
+	defer out.Close()
+	err = util.WriteArchive(out, format, files)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("failed to write archive")
+		os.Exit(1)
+	}
+	log.Info().Msgf("wrote archive to '%s'\n", path)
+}
 
-	} else if outputPath != "" && targetCount > 1 || templateCount > 1 {
-		// write multiple files in directory using template name
-		err := os.MkdirAll(filepath.Clean(outputPath), 0o755)
+// Returns the generated files to write to an archive. Files are named using
+// their output path if the target sets one or the file name otherwise with
+// the mode set for the target.
+func archiveFiles(conf config.Config, results []targetOutput) ([]util.ArchiveFile, error) {
+	files := []util.ArchiveFile{}
+	for _, result := range results {
+		mode, err := conf.Targets[result.target].FileMode(0o644)
 		if err != nil {
-			log.Error().Err(err).Str("path", filepath.Clean(outputPath)).Msg("failed to make output directory")
-			os.Exit(1)
+			return nil, err
 		}
-		for path, contents := range outputBytes {
-			filename := filepath.Base(path)
-			cleanPath := fmt.Sprintf("%s/%s", filepath.Clean(outputPath), filename)
-			err := os.WriteFile(cleanPath, contents, 0o755)
-			if err != nil {
-				log.Error().Err(err).Str("path", path).Msg("failed to write config to file")
-				os.Exit(1)
+		for path, contents := range result.outputs {
+			if !result.mapped {
+				path = filepath.Base(path)
 			}
-			log.Info().Msgf("wrote file to '%s'\n", cleanPath)
+			files = append(files, util.ArchiveFile{Name: path, Contents: contents, Mode: mode})
+		}
+	}
+	return files, nil
+}
+
+func writeOutputFile(path string, contents []byte) {
+	err := os.WriteFile(path, contents, 0o644)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("failed to write config to file")
+		os.Exit(1)
+	}
+	log.Info().Msgf("wrote file to '%s'\n", path)
+}
+
+// Returns the path that each generated file is written to using the output
+// path set with the '-o/--output' flag. Files from targets that set their
+// outputs are written to their path inside of the output path. A single file
+// from a single target is written to the output path itself. Otherwise, files
+// are written inside of the output path as a directory using the file name.
+func outputFilePaths(result targetOutput, targetCount int) map[string]string {
+	var (
+		outputBytes = result.outputs
+		paths       = make(map[string]string, len(outputBytes))
+	)
+	for path := range outputBytes {
+		if result.mapped {
+			paths[path] = filepath.Join(filepath.Clean(outputPath), filepath.FromSlash(path))
+			continue
+		}
+		if targetCount <= 1 && len(outputBytes) == 1 {
+			paths[path] = outputPath
+			continue
 		}
+		paths[path] = filepath.Join(filepath.Clean(outputPath), filepath.Base(path))
 	}
+	return paths
 }
 
 func init() {
@@ -199,8 +408,12 @@ func init() {
 	generateCmd.Flags().StringVar(&pluginPath, "plugin", "", "set the generator plugin path")
 	generateCmd.Flags().StringVarP(&outputPath, "output", "o", "", "set the output path for conf targets")
 	generateCmd.Flags().IntVar(&tokenFetchRetries, "fetch-retries", 5, "set the number of retries to fetch an access token")
-	generateCmd.Flags().StringVar(&remoteHost, "host", "http://localhost", "set the remote host")
-	generateCmd.Flags().BoolVar(&useCompression, "compress", false, "set whether to archive and compress multiple file outputs")
+	generateCmd.Flags().StringVar(&remoteHost, "host", "", "set the SMD host (overrides 'smd.host' in config)")
+	generateCmd.Flags().BoolVar(&useCompression, "compress", false, "set whether to archive and compress the file outputs")
+	generateCmd.Flags().StringArrayVar(&setVars, "set", []string{}, "set a variable passed to templates as key=value (overrides 'vars' in config)")
+	generateCmd.Flags().BoolVar(&strictTemplates, "strict", false, "set whether undefined variables in templates are an error (overrides 'strict' in config)")
+	generateCmd.Flags().BoolVar(&showGraph, "graph", false, "print the targets and the targets they run as a graph in DOT format without generating")
+	generateCmd.Flags().StringVar(&archiveFormat, "archive-format", string(util.ArchiveTarGz), "set the archive format when compressing (tar.gz, tar.zst, or zip)")
 
 	// requires either 'target' by itself or 'plugin' and 'templates' together
 	// generateCmd.MarkFlagsOneRequired("target", "plugin")
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/fs"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/OpenCHAMI/configurator/pkg/generator"
@@ -14,19 +15,27 @@ import (
 )
 
 var (
-	byTarget bool
+	byTarget    bool
+	showFilters bool
 )
 
 var inspectCmd = &cobra.Command{
 	Use:   "inspect",
 	Short: "Inspect generator plugin information",
-	Long:  "The 'inspect' sub-command takes a list of directories and prints all found plugin information.",
+	Long: "The 'inspect' sub-command takes a list of directories and prints all found plugin information.\n\n" +
+		"Set '--filters' to print the custom filters available in templates instead.",
 	Run: func(cmd *cobra.Command, args []string) {
 		// set up table formatter
 		table.DefaultHeaderFormatter = func(format string, vals ...interface{}) string {
 			return strings.ToUpper(fmt.Sprintf(format, vals...))
 		}
 
+		// print the template filters instead of plugins if requested
+		if showFilters {
+			printFilters()
+			return
+		}
+
 		// remove duplicate clean paths from CLI
 		paths := make([]string, len(args))
 		for _, path := range args {
@@ -69,7 +78,25 @@ var inspectCmd = &cobra.Command{
 	},
 }
 
+// Prints the name, usage, and description of each custom template filter
+// sorted by name.
+func printFilters() {
+	names := make([]string, 0, len(generator.DefaultFilters))
+	for name := range generator.DefaultFilters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tbl := table.New("Name", "Usage", "Description")
+	for _, name := range names {
+		f := generator.DefaultFilters[name]
+		tbl.AddRow(f.Name, f.Usage, f.Description)
+	}
+	tbl.Print()
+}
+
 func init() {
 	inspectCmd.Flags().BoolVar(&byTarget, "by-target", false, "set whether to ")
+	inspectCmd.Flags().BoolVar(&showFilters, "filters", false, "set whether to print the filters available in templates")
 	rootCmd.AddCommand(inspectCmd)
 }
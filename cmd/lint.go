@@ -0,0 +1,101 @@
+//go:build client || all
+// +build client all
+
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check the templates used by targets for errors",
+	Long: "Parse every template used by the targets set with '--target' or every target in\n" +
+		"the config if none are set. Syntax errors are printed with their line and column\n" +
+		"along with the variables each generator provides and the variables each template uses.\n\n" +
+		"Variables that are used but not provided are undefined. Templates that are rendered\n" +
+		"for each item in a list only warn about them since they may be fields of the item.\n\n" +
+		"Exits with 0 if there are no problems, 1 if there are problems, and 2 if an error occurs.",
+	Run: func(cmd *cobra.Command, args []string) {
+		// variables set with '--set' are provided to every target
+		vars, err := parseVars(setVars)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to parse variables")
+			os.Exit(2)
+		}
+		if conf.Vars == nil {
+			conf.Vars = map[string]any{}
+		}
+		maps.Copy(conf.Vars, vars)
+
+		// lint every target in the config if none are set
+		lintTargets := targets
+		if len(lintTargets) == 0 {
+			for target := range conf.Targets {
+				lintTargets = append(lintTargets, target)
+			}
+			sort.Strings(lintTargets)
+		}
+		if len(lintTargets) == 0 {
+			log.Error().Msg("no targets to lint")
+			os.Exit(2)
+		}
+
+		problems := 0
+		for _, target := range lintTargets {
+			provides, results, err := generator.LintTarget(&conf, target)
+			if err != nil {
+				log.Error().Err(err).Str("target", target).Msg("failed to lint target")
+				os.Exit(2)
+			}
+			problems += printLintResults(target, provides, results)
+		}
+		if problems > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// Prints the variables provided for the target and the results for each of
+// its templates. Returns the number of templates with problems.
+func printLintResults(target string, provides []string, results []generator.LintResult) int {
+	problems := 0
+	if provides != nil {
+		fmt.Printf("%s provides: %s\n", target, strings.Join(provides, ", "))
+	} else {
+		fmt.Printf("%s provides: (not listed by generator)\n", target)
+	}
+	for _, result := range results {
+		fmt.Printf("  %s\n", result.Path)
+		if result.Err != nil {
+			fmt.Printf("    error: %v\n", result.Err)
+			problems++
+			continue
+		}
+		fmt.Printf("    uses: %s\n", strings.Join(result.Uses, ", "))
+		if len(result.Undefined) == 0 {
+			continue
+		}
+		if result.ForEach != "" {
+			fmt.Printf("    warning: not provided (may be fields of each item in '%s'): %s\n", result.ForEach, strings.Join(result.Undefined, ", "))
+			continue
+		}
+		fmt.Printf("    undefined: %s\n", strings.Join(result.Undefined, ", "))
+		problems++
+	}
+	return problems
+}
+
+func init() {
+	lintCmd.Flags().StringSliceVar(&targets, "target", []string{}, "set the targets to lint (lints every target in the config if not set)")
+	lintCmd.Flags().StringArrayVar(&setVars, "set", []string{}, "set a variable passed to templates as key=value (overrides 'vars' in config)")
+	rootCmd.AddCommand(lintCmd)
+}
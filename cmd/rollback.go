@@ -0,0 +1,84 @@
+//go:build client || all
+// +build client all
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/OpenCHAMI/configurator/pkg/state"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rollbackTarget  string
+	rollbackVersion int
+	listVersions    bool
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Restore files previously written with 'apply' and re-run hooks",
+	Long: "Restore the files for a target from a version kept in the state directory and\n" +
+		"run the target's hooks. The version applied before the current one is restored\n" +
+		"by default, skipping versions that were rolled back from.\n" +
+		"Use '--list' to show the versions that are kept.",
+	Run: func(cmd *cobra.Command, args []string) {
+		var (
+			store  = state.NewStore(conf.State.Path, conf.State.Keep)
+			target = conf.Targets[rollbackTarget]
+		)
+
+		versions, err := store.List(rollbackTarget)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to list versions")
+			os.Exit(1)
+		}
+
+		// show the versions that can be restored
+		if listVersions {
+			for _, meta := range versions {
+				paths := make([]string, 0, len(meta.Files))
+				for path := range meta.Files {
+					paths = append(paths, path)
+				}
+				sort.Strings(paths)
+				fmt.Printf("%d\t%s\tgenerator=%s\tinventory=%.12s\t%v\n",
+					meta.Version, meta.Timestamp.Format("2006-01-02T15:04:05Z"),
+					meta.GeneratorVersion, meta.InventoryHash, paths)
+			}
+			return
+		}
+
+		// restore the version applied before the current one if one is not
+		// specified
+		version := rollbackVersion
+		if version == 0 {
+			previous, err := store.Previous(rollbackTarget)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to find version to roll back to")
+				os.Exit(1)
+			}
+			version = previous.Version
+		}
+
+		meta, err := store.Restore(target, rollbackTarget, version)
+		if err != nil {
+			log.Error().Err(err).Str("target", rollbackTarget).Msg("failed to roll back")
+			os.Exit(1)
+		}
+		log.Info().Str("target", rollbackTarget).Int("version", meta.RollbackOf).Msg("restored files")
+	},
+}
+
+func init() {
+	rollbackCmd.Flags().StringVar(&rollbackTarget, "target", "", "set the target to roll back")
+	rollbackCmd.Flags().IntVar(&rollbackVersion, "to", 0, "set the version to restore (defaults to the version applied before the current one)")
+	rollbackCmd.Flags().BoolVar(&listVersions, "list", false, "list the versions kept for the target")
+	rollbackCmd.MarkFlagRequired("target")
+
+	rootCmd.AddCommand(rollbackCmd)
+}
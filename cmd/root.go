@@ -14,6 +14,7 @@ var (
 	conf        config.Config
 	configPath  string
 	cacertPath  string
+	insecure    bool
 	verbose     bool
 	targets     []string
 	outputPath  string
@@ -44,6 +45,7 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "set the config path")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "set to enable verbose output")
 	rootCmd.PersistentFlags().StringVar(&cacertPath, "cacert", "", "path to CA cert. (defaults to system CAs)")
+	rootCmd.PersistentFlags().BoolVar(&insecure, "insecure", false, "set to skip verifying certificates (overrides 'insecure' in config)")
 }
 
 func InitConfig() {
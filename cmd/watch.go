@@ -0,0 +1,141 @@
+//go:build client || all
+// +build client all
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/OpenCHAMI/configurator/pkg/client"
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+	"github.com/OpenCHAMI/configurator/pkg/state"
+	"github.com/OpenCHAMI/configurator/pkg/watch"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchInterval   time.Duration
+	watchDebounce   time.Duration
+	watchMaxBackoff time.Duration
+	scnListenAddr   string
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Regenerate and apply targets when the inventory in SMD changes",
+	Long: "Poll SMD on an interval and apply the targets like 'apply' whenever the inventory\n" +
+		"used by a target changes. Set '--listen' to also receive state change notifications\n" +
+		"(SCN) with a POST to '/scn' to regenerate without waiting for the next interval.",
+	Run: func(cmd *cobra.Command, args []string) {
+		prepareGenerate(cmd)
+
+		var (
+			store  = state.NewStore(conf.State.Path, conf.State.Keep)
+			hashes = map[string]string{}
+			inputs = map[string]*client.Cache{}
+		)
+
+		// only generate and apply targets when the inventory they use has
+		// changed by fetching the inventory used last time again first
+		run := func(ctx context.Context, reason string) error {
+			defer logCacheStats()
+
+			graph, err := generator.ResolveTargets(&conf, targets...)
+			if err != nil {
+				return err
+			}
+			err = inventoryCache.Refresh(ctx)
+			if err != nil {
+				return err
+			}
+			changed := []string{}
+			for _, target := range graph.Targets {
+				tracked, ok := inputs[target]
+				if ok && tracked.Hash() == hashes[target] {
+					log.Debug().Str("target", target).Str("hash", hashes[target]).Msg("inventory unchanged")
+					continue
+				}
+				changed = append(changed, target)
+			}
+			if len(changed) == 0 {
+				return nil
+			}
+
+			results, err := generateTargets(ctx, &conf, changed...)
+			if err != nil {
+				return err
+			}
+			for _, result := range results {
+				if hash, ok := hashes[result.target]; ok && hash == result.inputHash {
+					log.Debug().Str("target", result.target).Str("hash", result.inputHash).Msg("inventory unchanged")
+					continue
+				}
+				log.Info().Str("target", result.target).Str("hash", result.inputHash).Str("reason", reason).Msg("inventory changed")
+				err = applyTarget(store, result, len(graph.Targets))
+				if err != nil {
+					return err
+				}
+				hashes[result.target] = result.inputHash
+				inputs[result.target] = result.inputs
+			}
+			return nil
+		}
+
+		var (
+			ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			watcher   = watch.NewWatcher(run,
+				watch.WithInterval(watchInterval),
+				watch.WithDebounce(watchDebounce),
+				watch.WithBackoff(watch.DefaultMinBackoff, watchMaxBackoff),
+			)
+		)
+		defer stop()
+
+		// listen for state change notifications if an address is set
+		if scnListenAddr != "" {
+			mux := http.NewServeMux()
+			mux.Handle("/scn", watcher.Handler())
+			server := &http.Server{Addr: scnListenAddr, Handler: mux}
+			go func() {
+				log.Info().Str("addr", scnListenAddr).Msg("listening for state change notifications")
+				err := server.ListenAndServe()
+				if err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Error().Err(err).Msg("failed to listen for state change notifications")
+					stop()
+				}
+			}()
+			defer server.Shutdown(context.Background())
+		}
+
+		log.Info().Strs("targets", targets).Dur("interval", watchInterval).Msg("watching for inventory changes")
+		err := watcher.Start(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Error().Err(err).Msg("failed to watch for changes")
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	watchCmd.Flags().StringSliceVar(&targets, "target", []string{}, "set the targets to regenerate when the inventory changes")
+	watchCmd.Flags().StringVarP(&outputPath, "output", "o", "", "set the output path to write files to")
+	watchCmd.Flags().StringVar(&remoteHost, "host", "", "set the SMD host (overrides 'smd.host' in config)")
+	watchCmd.Flags().StringArrayVar(&setVars, "set", []string{}, "set a variable passed to templates as key=value (overrides 'vars' in config)")
+	watchCmd.Flags().BoolVar(&strictTemplates, "strict", false, "set whether undefined variables in templates are an error (overrides 'strict' in config)")
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", watch.DefaultInterval, "set how often to poll SMD (0 to only regenerate on notifications)")
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", watch.DefaultDebounce, "set how long to wait for notifications to settle before regenerating")
+	watchCmd.Flags().DurationVar(&watchMaxBackoff, "max-backoff", watch.DefaultMaxBackoff, "set the longest time to wait before retrying after a failure")
+	watchCmd.Flags().StringVar(&scnListenAddr, "listen", "", "set the address to listen for state change notifications (e.g. ':27780')")
+
+	watchCmd.MarkFlagRequired("target")
+	watchCmd.MarkFlagRequired("output")
+
+	rootCmd.AddCommand(watchCmd)
+}
@@ -1,10 +1,12 @@
 module github.com/OpenCHAMI/configurator
 
-go 1.21.5
+go 1.22
 
 require (
 	github.com/OpenCHAMI/jwtauth/v5 v5.0.0-20240321222802-e6cb468a2a18
 	github.com/go-chi/chi/v5 v5.1.0
+	github.com/go-task/slim-sprig v0.0.0-20230315185526-52ccab3ef572
+	github.com/klauspost/compress v1.18.0
 	github.com/lestrrat-go/jwx/v2 v2.1.1
 	github.com/nikolalohinski/gonja/v2 v2.2.0
 	github.com/openchami/chi-middleware/auth v0.0.0-20240812224658-b16b83c70700
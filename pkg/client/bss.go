@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+)
+
+// A struct similar to the SmdClient that makes requests to the Boot Script
+// Service (BSS) to fetch the kernel, initrd, and kernel parameters used to
+// boot each node.
+type BssClient struct {
+	http.Client `json:"-" yaml:"-"`
+	Host        string        `yaml:"host"`
+	AccessToken string        `yaml:"access-token"`
+	Retries     int           `yaml:"retries,omitempty"`
+	RetryWait   time.Duration `yaml:"retry-wait,omitempty"`
+	Cache       *Cache        `json:"-" yaml:"-"`
+}
+
+// Constructor function that allows supplying Option arguments to set
+// things like the host, access token, etc. Returns an error if any of the
+// options can't be applied.
+func NewBssClient(opts ...Option) (BssClient, error) {
+	params, err := ToParams(opts...)
+	if err != nil {
+		return BssClient{}, fmt.Errorf("failed to create BSS client: %w", err)
+	}
+	return BssClient{
+		Client:      params.NewHTTPClient(),
+		Host:        params.Host,
+		AccessToken: params.AccessToken,
+		Retries:     params.Retries,
+		RetryWait:   params.RetryWait,
+		Cache:       params.Cache,
+	}, nil
+}
+
+// Fetch the boot parameters for all nodes from BSS using its API.
+func (client *BssClient) FetchBootParameters(ctx context.Context, verbose bool) ([]configurator.BootParameters, error) {
+	return fetchList[configurator.BootParameters](ctx, client, "/bootparameters", "bootparameters", verbose)
+}
+
+func (client *BssClient) makeRequest(ctx context.Context, endpoint string) ([]byte, error) {
+	if client == nil {
+		return nil, fmt.Errorf("client is nil")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	url := fmt.Sprintf("%s/boot/v1%s", client.Host, endpoint)
+	fetch := func(ctx context.Context) ([]byte, error) {
+		return getWithRetries(ctx, &client.Client, url, client.AccessToken, client.Retries, client.RetryWait)
+	}
+
+	// only fetch each resource once if the client has a cache
+	if client.Cache != nil {
+		return client.Cache.Fetch(ctx, url, fetch)
+	}
+	return fetch(ctx)
+}
@@ -0,0 +1,210 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// An in-memory cache of response bodies keyed by URL that can be shared between
+// clients so that each resource is only fetched once. When running from the
+// CLI, a cache is created for a single run and entries never expire. When
+// running as a service, entries expire after the TTL set.
+type Cache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	hits    int
+	misses  int
+
+	// set for caches returned by Track() to record the keys fetched
+	parent *Cache
+	keys   map[string]struct{}
+}
+
+type cacheEntry struct {
+	body    []byte
+	err     error
+	done    chan struct{}
+	expires time.Time
+	fetch   func(ctx context.Context) ([]byte, error)
+}
+
+// Creates a new cache where entries expire after the TTL. A TTL of zero means
+// that entries never expire.
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		entries: map[string]*cacheEntry{},
+	}
+}
+
+// Returns the cached response for the key or calls fetch with the context to
+// get it. Concurrent calls for the same key will wait for the first fetch to
+// finish instead of making duplicate requests. Errors are returned to everyone
+// waiting, but are not cached so the next call will try to fetch again.
+func (c *Cache) Fetch(ctx context.Context, key string, fetch func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if c.parent != nil {
+		c.mu.Lock()
+		c.keys[key] = struct{}{}
+		c.mu.Unlock()
+		return c.parent.Fetch(ctx, key, fetch)
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && (c.ttl <= 0 || time.Now().Before(e.expires)) {
+		c.hits++
+		c.mu.Unlock()
+		log.Debug().Str("key", key).Msg("cache hit")
+		<-e.done
+		return e.body, e.err
+	}
+	e := &cacheEntry{done: make(chan struct{}), fetch: fetch}
+	c.entries[key] = e
+	c.misses++
+	c.mu.Unlock()
+	log.Debug().Str("key", key).Msg("cache miss")
+
+	e.body, e.err = fetch(ctx)
+	c.mu.Lock()
+	if e.err != nil {
+		delete(c.entries, key)
+	} else {
+		e.expires = time.Now().Add(c.ttl)
+	}
+	c.mu.Unlock()
+	close(e.done)
+
+	return e.body, e.err
+}
+
+// Returns a cache that shares entries with this cache, but records the keys
+// fetched through it. Calling Hash() on the returned cache only includes the
+// responses fetched through it, which makes it possible to tell which inputs
+// a single generator used when the cache is shared.
+func (c *Cache) Track() *Cache {
+	parent := c
+	if c.parent != nil {
+		parent = c.parent
+	}
+	return &Cache{parent: parent, keys: map[string]struct{}{}}
+}
+
+// Fetches every response in the cache again with the function that first
+// fetched it and replaces the cached response. This makes it possible to tell
+// if the inventory changed with Hash() without generating files again. The
+// first error is returned and the remaining responses are not fetched.
+func (c *Cache) Refresh(ctx context.Context) error {
+	if c.parent != nil {
+		return c.parent.Refresh(ctx)
+	}
+
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		c.mu.Lock()
+		e, ok := c.entries[key]
+		c.mu.Unlock()
+		if !ok {
+			continue
+		}
+		<-e.done
+		body, err := e.fetch(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to refresh '%s': %w", key, err)
+		}
+		refreshed := &cacheEntry{
+			body:    body,
+			done:    make(chan struct{}),
+			expires: time.Now().Add(c.ttl),
+			fetch:   e.fetch,
+		}
+		close(refreshed.done)
+		c.mu.Lock()
+		c.entries[key] = refreshed
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// Removes all entries from the cache.
+func (c *Cache) Clear() {
+	if c.parent != nil {
+		c.mu.Lock()
+		c.keys = map[string]struct{}{}
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]*cacheEntry{}
+}
+
+// Returns the number of cache hits and misses since the cache was created.
+func (c *Cache) Stats() (hits int, misses int) {
+	if c.parent != nil {
+		return c.parent.Stats()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Returns a SHA-256 hash of every response body in the cache and its key. The
+// hash can be used to tell if the inventory used to generate files changed
+// between runs. Entries that are still being fetched or failed are skipped.
+func (c *Cache) Hash() string {
+	if c.parent != nil {
+		c.mu.Lock()
+		keys := make([]string, 0, len(c.keys))
+		for key := range c.keys {
+			keys = append(keys, key)
+		}
+		c.mu.Unlock()
+		return c.parent.hash(keys)
+	}
+
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+	return c.hash(keys)
+}
+
+func (c *Cache) hash(keys []string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, key := range keys {
+		e, ok := c.entries[key]
+		if !ok {
+			continue
+		}
+		select {
+		case <-e.done:
+		default:
+			continue
+		}
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write(e.body)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -3,46 +3,129 @@ package client
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"time"
 )
 
-type Option func(*Params)
+const (
+	DefaultTimeout   = 30 * time.Second
+	DefaultRetries   = 3
+	DefaultRetryWait = 500 * time.Millisecond
+)
+
+// Sets a parameter used to create a client. Options return an error when the
+// value can't be used so that a client is never created without it.
+type Option func(*Params) error
 type Params struct {
 	Host        string `yaml:"host"`
 	AccessToken string `yaml:"access-token"`
 	Transport   *http.Transport
+	Timeout     time.Duration
+	Retries     int
+	RetryWait   time.Duration
+	Cache       *Cache
+	ProxyURL    *url.URL
+	Insecure    bool
 }
 
-func ToParams(opts ...Option) *Params {
-	params := &Params{}
+func ToParams(opts ...Option) (*Params, error) {
+	params := &Params{
+		Timeout:   DefaultTimeout,
+		Retries:   DefaultRetries,
+		RetryWait: DefaultRetryWait,
+	}
 	for _, opt := range opts {
-		opt(params)
+		if err := opt(params); err != nil {
+			return nil, err
+		}
 	}
-	return params
+	return params, nil
 }
 
 func WithHost(host string) Option {
-	return func(c *Params) {
+	return func(c *Params) error {
 		c.Host = host
+		return nil
 	}
 }
 
 func WithAccessToken(token string) Option {
-	return func(c *Params) {
+	return func(c *Params) error {
 		c.AccessToken = token
+		return nil
 	}
 }
 
+// Sets the timeout for each request made by the client. A timeout of zero
+// means no timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Params) error {
+		c.Timeout = timeout
+		return nil
+	}
+}
+
+// Sets the number of times a request is retried after a network or server
+// error. The wait time between each retry is doubled after every attempt.
+func WithRetries(retries int, wait time.Duration) Option {
+	return func(c *Params) error {
+		c.Retries = retries
+		c.RetryWait = wait
+		return nil
+	}
+}
+
+// Sets the proxy used for all requests made by the client. Otherwise, the
+// proxy is taken from the HTTP_PROXY and HTTPS_PROXY environment variables.
+// Returns an error if the proxy is not a valid URL.
+func WithProxy(proxy string) Option {
+	return func(c *Params) error {
+		if proxy == "" {
+			return nil
+		}
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return fmt.Errorf("failed to parse proxy URL: %w", err)
+		}
+		if proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return fmt.Errorf("failed to parse proxy URL: '%s' must include a scheme and host", proxy)
+		}
+		c.ProxyURL = proxyURL
+		return nil
+	}
+}
+
+// Sets the cache to share responses between clients.
+func WithCache(cache *Cache) Option {
+	return func(c *Params) error {
+		c.Cache = cache
+		return nil
+	}
+}
+
+// Sets whether the client skips verifying the service certificates. This
+// should only be used for testing since requests can then be intercepted.
+func WithInsecure(insecure bool) Option {
+	return func(c *Params) error {
+		c.Insecure = insecure
+		return nil
+	}
+}
+
+// Sets the CA pool used to verify the service certificates. Certificates are
+// verified unless WithInsecure() is also set, and the proxy is taken from the
+// environment like the default transport.
 func WithCertPool(certPool *x509.CertPool) Option {
-	return func(c *Params) {
+	return func(c *Params) error {
 		c.Transport = &http.Transport{
 			TLSClientConfig: &tls.Config{
-				RootCAs:            certPool,
-				InsecureSkipVerify: true,
+				RootCAs: certPool,
 			},
+			Proxy:             http.ProxyFromEnvironment,
 			DisableKeepAlives: true,
 			Dial: (&net.Dialer{
 				Timeout:   120 * time.Second,
@@ -51,16 +134,58 @@ func WithCertPool(certPool *x509.CertPool) Option {
 			TLSHandshakeTimeout:   120 * time.Second,
 			ResponseHeaderTimeout: 120 * time.Second,
 		}
+		return nil
 	}
 }
 
-// FIXME: Need to check for errors when reading from a file
+// Sets the CA bundle used to verify the service certificates from a file. The
+// system CAs are used if no path is set. Returns an error if the file cannot
+// be read or has no certificates.
 func WithCertPoolFile(certPath string) Option {
-	if certPath == "" {
-		return func(sc *Params) {}
+	return func(c *Params) error {
+		if certPath == "" {
+			return nil
+		}
+		cacert, err := os.ReadFile(certPath)
+		if err != nil {
+			return fmt.Errorf("failed to read CA cert file: %w", err)
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(cacert) {
+			return fmt.Errorf("no valid certificates found in CA cert file '%s'", certPath)
+		}
+		return WithCertPool(certPool)(c)
+	}
+}
+
+// Returns a HTTP client with the transport, proxy, TLS verification, and
+// timeout from the params applied. The default transport is used if no CA
+// pool, proxy, or insecure option is set.
+func (params *Params) NewHTTPClient() http.Client {
+	var transport http.RoundTripper
+	if params.Transport != nil {
+		transport = params.Transport
+	}
+	if params.ProxyURL != nil || params.Insecure {
+		t := params.Transport
+		if t == nil {
+			t = http.DefaultTransport.(*http.Transport).Clone()
+		} else {
+			t = t.Clone()
+		}
+		if params.ProxyURL != nil {
+			t.Proxy = http.ProxyURL(params.ProxyURL)
+		}
+		if params.Insecure {
+			if t.TLSClientConfig == nil {
+				t.TLSClientConfig = &tls.Config{}
+			}
+			t.TLSClientConfig.InsecureSkipVerify = true
+		}
+		transport = t
+	}
+	return http.Client{
+		Transport: transport,
+		Timeout:   params.Timeout,
 	}
-	cacert, _ := os.ReadFile(certPath)
-	certPool := x509.NewCertPool()
-	certPool.AppendCertsFromPEM(cacert)
-	return WithCertPool(certPool)
 }
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// Returned (wrapped in a *StatusError) when a service responds with a
+	// 4xx status code. These requests are not retried.
+	ErrClientError = errors.New("client error")
+
+	// Returned (wrapped in a *StatusError) when a service responds with a
+	// 5xx status code after all retries have been exhausted.
+	ErrServerError = errors.New("server error")
+)
+
+// Error returned when a service responds with a non-2xx status code. Use
+// errors.Is() with ErrClientError or ErrServerError to check the class of
+// error, or errors.As() to inspect the status code and response body.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status code %d (%s): %s",
+		e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode), strings.TrimSpace(string(e.Body)))
+}
+
+func (e *StatusError) Unwrap() error {
+	if e.StatusCode >= 500 {
+		return ErrServerError
+	}
+	return ErrClientError
+}
+
+// Returns true if the request should be attempted again. Only server errors
+// and rate limiting are considered to be temporary.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// Makes a GET request to a URL with the client, retrying with exponential
+// backoff when the request fails because of a network or server error. The
+// response body is always closed and only returned for 2xx responses.
+func getWithRetries(ctx context.Context, c *http.Client, url string, accessToken string, retries int, retryWait time.Duration) ([]byte, error) {
+	var (
+		wait = retryWait
+		b    []byte
+		err  error
+	)
+	for attempt := 0; ; attempt++ {
+		b, err = get(ctx, c, url, accessToken)
+		if err == nil {
+			return b, nil
+		}
+
+		// only retry temporary errors and stop when the context is done
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && !statusErr.Temporary() {
+			return nil, err
+		}
+		if ctx.Err() != nil || attempt >= retries {
+			return nil, err
+		}
+
+		log.Debug().Err(err).Str("url", url).Int("attempt", attempt+1).Dur("wait", wait).Msg("retrying request")
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func get(ctx context.Context, c *http.Client, url string, accessToken string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new HTTP request: %w", err)
+	}
+
+	// include access token in authorzation header if found
+	if accessToken != "" {
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+	}
+	req.Header.Add("Accept", "application/json")
+
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer res.Body.Close()
+
+	// read the contents of the response body
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &StatusError{
+			Method:     req.Method,
+			URL:        url,
+			StatusCode: res.StatusCode,
+			Body:       b,
+		}
+	}
+	return b, nil
+}
+
+// Decodes a JSON list from a response body that is either a bare array or an
+// object with the list stored under the key (e.g. {"Components": [...]}). The
+// key is matched case-insensitively and a null or missing list decodes to
+// an empty list instead of failing.
+func decodeList[T any](b []byte, key string) ([]T, error) {
+	var (
+		list = []T{}
+		obj  map[string]json.RawMessage
+	)
+
+	b = []byte(strings.TrimSpace(string(b)))
+	if len(b) == 0 || string(b) == "null" {
+		return list, nil
+	}
+
+	// a bare array can be decoded as is
+	if b[0] == '[' {
+		if err := json.Unmarshal(b, &list); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal list: %w", err)
+		}
+		return list, nil
+	}
+
+	// otherwise, look for the list in the object
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, fmt.Errorf("expected a JSON array or object: %w", err)
+	}
+	for k, v := range obj {
+		if !strings.EqualFold(k, key) {
+			continue
+		}
+		if string(v) == "null" {
+			return list, nil
+		}
+		if err := json.Unmarshal(v, &list); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal '%s': %w", key, err)
+		}
+		return list, nil
+	}
+	log.Debug().Str("key", key).Msg("list not found in response")
+	return list, nil
+}
+
+// Clients that make requests to a service endpoint.
+type requester interface {
+	makeRequest(ctx context.Context, endpoint string) ([]byte, error)
+}
+
+// Makes a request to the service endpoint and decodes the list found in the
+// response. SMD wraps most lists in an object using the key, but bare arrays
+// are accepted as well.
+func fetchList[T any](ctx context.Context, client requester, endpoint string, key string, verbose bool) ([]T, error) {
+	// make request to service endpoint
+	b, err := client.makeRequest(ctx, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make HTTP request: %w", err)
+	}
+
+	// unmarshal response body JSON and extract in object
+	list, err := decodeList[T](b, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response from '%s': %w", endpoint, err)
+	}
+
+	// print what we got if verbose is set
+	if verbose {
+		b, _ = json.Marshal(list)
+		log.Info().RawJSON(key, b).Int("count", len(list)).Msgf("found %s", key)
+	}
+
+	return list, nil
+}
@@ -1,14 +1,12 @@
 package client
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"net/http"
+	"time"
 
 	configurator "github.com/OpenCHAMI/configurator/pkg"
-	"github.com/rs/zerolog/log"
 )
 
 // An struct that's meant to extend functionality of the base HTTP client by
@@ -17,158 +15,87 @@ import (
 // values for the Jinja templates used.
 type SmdClient struct {
 	http.Client `json:"-" yaml:"-"`
-	Host        string `yaml:"host"`
-	Port        int    `yaml:"port"`
-	AccessToken string `yaml:"access-token"`
+	Host        string        `yaml:"host"`
+	Port        int           `yaml:"port"`
+	AccessToken string        `yaml:"access-token"`
+	Retries     int           `yaml:"retries,omitempty"`
+	RetryWait   time.Duration `yaml:"retry-wait,omitempty"`
+	Cache       *Cache        `json:"-" yaml:"-"`
 }
 
 // Constructor function that allows supplying Option arguments to set
-// things like the host, port, access token, etc.
-func NewSmdClient(opts ...Option) SmdClient {
-	var (
-		params = ToParams(opts...)
-		client = SmdClient{
-			Host:        params.Host,
-			AccessToken: params.AccessToken,
-		}
-	)
-
-	return client
-}
-
-// Fetch the ethernet interfaces from SMD service using its API. An access token may be required if the SMD
-// service SMD_JWKS_URL envirnoment variable is set.
-func (client *SmdClient) FetchEthernetInterfaces(verbose bool) ([]configurator.EthernetInterface, error) {
-	var (
-		eths  = []configurator.EthernetInterface{}
-		bytes []byte
-		err   error
-	)
-	// make request to SMD endpoint
-	bytes, err = client.makeRequest("/Inventory/EthernetInterfaces")
-	if err != nil {
-		return nil, fmt.Errorf("failed to read HTTP response: %v", err)
-	}
-
-	// unmarshal response body JSON and extract in object
-	err = json.Unmarshal(bytes, &eths)
+// things like the host, port, access token, etc. Returns an error if any of
+// the options can't be applied.
+func NewSmdClient(opts ...Option) (SmdClient, error) {
+	params, err := ToParams(opts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+		return SmdClient{}, fmt.Errorf("failed to create SMD client: %w", err)
 	}
-
-	// print what we got if verbose is set
-	if verbose {
-		log.Info().Str("ethernet_interfaces", string(bytes)).Msg("found interfaces")
+	client := SmdClient{
+		Client:      params.NewHTTPClient(),
+		Host:        params.Host,
+		AccessToken: params.AccessToken,
+		Retries:     params.Retries,
+		RetryWait:   params.RetryWait,
+		Cache:       params.Cache,
 	}
+	return client, nil
+}
 
-	return eths, nil
+// Fetch the ethernet interfaces from SMD service using its API. An access token may be required if the SMD
+// service SMD_JWKS_URL envirnoment variable is set.
+func (client *SmdClient) FetchEthernetInterfaces(ctx context.Context, verbose bool) ([]configurator.EthernetInterface, error) {
+	return fetchList[configurator.EthernetInterface](ctx, client, "/Inventory/EthernetInterfaces", "EthernetInterfaces", verbose)
 }
 
 // Fetch the components from SMD using its API. An access token may be required if the SMD
 // service SMD_JWKS_URL envirnoment variable is set.
-func (client *SmdClient) FetchComponents(verbose bool) ([]configurator.Component, error) {
-	var (
-		comps = []configurator.Component{}
-		bytes []byte
-		err   error
-	)
-	// make request to SMD endpoint
-	bytes, err = client.makeRequest("/State/Components")
-	if err != nil {
-		return nil, fmt.Errorf("failed to make HTTP request: %v", err)
-	}
-
-	// make sure our response is actually JSON
-	if !json.Valid(bytes) {
-		return nil, fmt.Errorf("expected valid JSON response: %v", string(bytes))
-	}
-
-	// unmarshal response body JSON and extract in object
-	var tmp map[string]any
-	err = json.Unmarshal(bytes, &tmp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
-	}
-	bytes, err = json.Marshal(tmp["RedfishEndpoints"].([]any))
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal JSON: %v", err)
-	}
-	err = json.Unmarshal(bytes, &comps)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
-	}
-
-	// print what we got if verbose is set
-	if verbose {
-		log.Info().Str("components", string(bytes)).Msg("found components")
-	}
-
-	return comps, nil
+func (client *SmdClient) FetchComponents(ctx context.Context, verbose bool) ([]configurator.Component, error) {
+	return fetchList[configurator.Component](ctx, client, "/State/Components", "Components", verbose)
 }
 
-// TODO: improve implementation of this function
-func (client *SmdClient) FetchRedfishEndpoints(verbose bool) ([]configurator.RedfishEndpoint, error) {
-	var (
-		eps = []configurator.RedfishEndpoint{}
-		tmp map[string]any
-	)
-
-	// make initial request to get JSON with 'RedfishEndpoints' as property
-	b, err := client.makeRequest("/Inventory/RedfishEndpoints")
-	if err != nil {
-		return nil, fmt.Errorf("failed to make HTTP resquest: %v", err)
-	}
-	// make sure response is in JSON
-	if !json.Valid(b) {
-		return nil, fmt.Errorf("expected valid JSON response: %v", string(b))
-	}
-	err = json.Unmarshal(b, &tmp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
-	}
+// Fetch the Redfish endpoints from SMD using its API.
+func (client *SmdClient) FetchRedfishEndpoints(ctx context.Context, verbose bool) ([]configurator.RedfishEndpoint, error) {
+	return fetchList[configurator.RedfishEndpoint](ctx, client, "/Inventory/RedfishEndpoints", "RedfishEndpoints", verbose)
+}
 
-	// marshal RedfishEndpoint JSON back to configurator.RedfishEndpoint
-	b, err = json.Marshal(tmp["RedfishEndpoints"].([]any))
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal JSON: %v", err)
-	}
-	err = json.Unmarshal(b, &eps)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
-	}
+// Fetch the hardware inventory from SMD using its API. The inventory includes
+// node, processor, and memory information such as CPU counts and total memory.
+func (client *SmdClient) FetchHardwareInventory(ctx context.Context, verbose bool) ([]configurator.HardwareInventory, error) {
+	return fetchList[configurator.HardwareInventory](ctx, client, "/Inventory/Hardware", "Hardware", verbose)
+}
 
-	// show the final result
-	if verbose {
-		log.Info().Str("redfish_endpoints", string(b)).Msg("found redfish endpoints")
-	}
+// Fetch the component endpoints from SMD using its API. Component endpoints
+// include the Redfish URLs used to manage nodes and BMCs (i.e. for power tools).
+func (client *SmdClient) FetchComponentEndpoints(ctx context.Context, verbose bool) ([]configurator.ComponentEndpoint, error) {
+	return fetchList[configurator.ComponentEndpoint](ctx, client, "/Inventory/ComponentEndpoints", "ComponentEndpoints", verbose)
+}
 
-	return eps, nil
+// Fetch the service endpoints from SMD using its API.
+func (client *SmdClient) FetchServiceEndpoints(ctx context.Context, verbose bool) ([]configurator.ServiceEndpoint, error) {
+	return fetchList[configurator.ServiceEndpoint](ctx, client, "/Inventory/ServiceEndpoints", "ServiceEndpoints", verbose)
 }
 
-func (client *SmdClient) makeRequest(endpoint string) ([]byte, error) {
+func (client *SmdClient) makeRequest(ctx context.Context, endpoint string) ([]byte, error) {
 	if client == nil {
 		return nil, fmt.Errorf("client is nil")
 	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
 	// fetch DHCP related information from SMD's endpoint:
 	url := fmt.Sprintf("%s/hsm/v2%s", client.Host, endpoint)
-	req, err := http.NewRequest(http.MethodGet, url, bytes.NewBuffer([]byte{}))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create new HTTP request: %v", err)
-	}
 
 	// include access token in authorzation header if found
 	// NOTE: This shouldn't be needed for this endpoint since it's public
-	if client.AccessToken != "" {
-		req.Header.Add("Authorization", "Bearer "+client.AccessToken)
+	fetch := func(ctx context.Context) ([]byte, error) {
+		return getWithRetries(ctx, &client.Client, url, client.AccessToken, client.Retries, client.RetryWait)
 	}
 
-	// make the request to SMD
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %v", err)
+	// only fetch each resource once if the client has a cache
+	if client.Cache != nil {
+		return client.Cache.Fetch(ctx, url, fetch)
 	}
-
-	// read the contents of the response body
-	return io.ReadAll(res.Body)
+	return fetch(ctx)
 }
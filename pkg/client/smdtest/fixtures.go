@@ -0,0 +1,93 @@
+package smdtest
+
+import (
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+)
+
+// Returns a small inventory with two nodes and their BMCs that can be used
+// as a starting point for tests.
+func DefaultFixtures() Fixtures {
+	enabled := true
+	return Fixtures{
+		Components: []configurator.Component{
+			{ID: "x1000c0s0b0", Type: "NodeBMC", State: "Ready", Enabled: &enabled},
+			{ID: "x1000c0s0b0n0", Type: "Node", State: "Ready", Role: "Compute", NID: "1", Arch: "X86", Enabled: &enabled},
+			{ID: "x1000c0s1b0", Type: "NodeBMC", State: "Ready", Enabled: &enabled},
+			{ID: "x1000c0s1b0n0", Type: "Node", State: "Ready", Role: "Compute", NID: "2", Arch: "X86", Enabled: &enabled},
+		},
+		EthernetInterfaces: []configurator.EthernetInterface{
+			{
+				Id:          "a4bf0138ee65",
+				MacAddress:  "a4:bf:01:38:ee:65",
+				ComponentId: "x1000c0s0b0",
+				Type:        "NodeBMC",
+				IpAddresses: []configurator.IPAddr{{IpAddress: "172.16.0.101"}},
+			},
+			{
+				Id:          "a4bf0138ee66",
+				MacAddress:  "a4:bf:01:38:ee:66",
+				ComponentId: "x1000c0s0b0n0",
+				Type:        "Node",
+				IpAddresses: []configurator.IPAddr{{IpAddress: "172.16.0.1"}},
+			},
+			{
+				Id:          "a4bf0138ee75",
+				MacAddress:  "a4:bf:01:38:ee:75",
+				ComponentId: "x1000c0s1b0",
+				Type:        "NodeBMC",
+				IpAddresses: []configurator.IPAddr{{IpAddress: "172.16.0.102"}},
+			},
+			{
+				Id:          "a4bf0138ee76",
+				MacAddress:  "a4:bf:01:38:ee:76",
+				ComponentId: "x1000c0s1b0n0",
+				Type:        "Node",
+				IpAddresses: []configurator.IPAddr{{IpAddress: "172.16.0.2"}},
+			},
+		},
+		RedfishEndpoints: []configurator.RedfishEndpoint{
+			{ID: "x1000c0s0b0", Type: "NodeBMC", Name: "x1000c0s0b0", Hostname: "x1000c0s0b0", FQDN: "x1000c0s0b0", Enabled: true, User: "root", Password: "secret", MACAddr: "a4:bf:01:38:ee:65", IPAddr: "172.16.0.101"},
+			{ID: "x1000c0s1b0", Type: "NodeBMC", Name: "x1000c0s1b0", Hostname: "x1000c0s1b0", FQDN: "x1000c0s1b0", Enabled: true, User: "root", Password: "secret", MACAddr: "a4:bf:01:38:ee:75", IPAddr: "172.16.0.102"},
+		},
+		Hardware: []configurator.HardwareInventory{
+			{
+				ID:                        "x1000c0s0b0n0",
+				Type:                      "Node",
+				Status:                    "Populated",
+				HWInventoryByLocationType: "HWInvByLocNode",
+				NodeLocationInfo: &configurator.NodeLocationInfo{
+					Id:               "Node0",
+					ProcessorSummary: configurator.ProcessorSummary{Count: 2, Model: "Intel(R) Xeon(R) Gold 6148"},
+					MemorySummary:    configurator.MemorySummary{TotalSystemMemoryGiB: 192},
+				},
+			},
+			{
+				ID:                        "x1000c0s1b0n0",
+				Type:                      "Node",
+				Status:                    "Populated",
+				HWInventoryByLocationType: "HWInvByLocNode",
+				NodeLocationInfo: &configurator.NodeLocationInfo{
+					Id:               "Node0",
+					ProcessorSummary: configurator.ProcessorSummary{Count: 2, Model: "Intel(R) Xeon(R) Gold 6148"},
+					MemorySummary:    configurator.MemorySummary{TotalSystemMemoryGiB: 192},
+				},
+			},
+		},
+		ComponentEndpoints: []configurator.ComponentEndpoint{
+			{ID: "x1000c0s0b0n0", Type: "Node", RedfishType: "ComputerSystem", OdataID: "/redfish/v1/Systems/Node0", RedfishEndpointID: "x1000c0s0b0", Enabled: true, RedfishEndpointFQDN: "x1000c0s0b0", RedfishURL: "x1000c0s0b0/redfish/v1/Systems/Node0", ComponentEndpointType: "ComponentEndpointComputerSystem"},
+			{ID: "x1000c0s1b0n0", Type: "Node", RedfishType: "ComputerSystem", OdataID: "/redfish/v1/Systems/Node0", RedfishEndpointID: "x1000c0s1b0", Enabled: true, RedfishEndpointFQDN: "x1000c0s1b0", RedfishURL: "x1000c0s1b0/redfish/v1/Systems/Node0", ComponentEndpointType: "ComponentEndpointComputerSystem"},
+		},
+		ServiceEndpoints: []configurator.ServiceEndpoint{
+			{RedfishEndpointID: "x1000c0s0b0", RedfishType: "UpdateService", OdataID: "/redfish/v1/UpdateService", RedfishEndpointFQDN: "x1000c0s0b0", RedfishURL: "x1000c0s0b0/redfish/v1/UpdateService"},
+			{RedfishEndpointID: "x1000c0s1b0", RedfishType: "UpdateService", OdataID: "/redfish/v1/UpdateService", RedfishEndpointFQDN: "x1000c0s1b0", RedfishURL: "x1000c0s1b0/redfish/v1/UpdateService"},
+		},
+		BootParameters: []configurator.BootParameters{
+			{
+				Macs:   []string{"a4:bf:01:38:ee:66", "a4:bf:01:38:ee:76"},
+				Kernel: "http://172.16.0.254/boot/vmlinuz",
+				Initrd: "http://172.16.0.254/boot/initramfs.img",
+				Params: "console=ttyS0,115200 ip=dhcp",
+			},
+		},
+	}
+}
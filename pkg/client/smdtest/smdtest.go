@@ -0,0 +1,163 @@
+// Package smdtest provides a fake SMD service for testing generators without
+// a live instance of SMD. The server serves the inventory from fixtures and
+// can inject errors and latency into responses. The boot parameters for the
+// BSS client are served as well so that the same server can be used as the
+// host for both clients.
+//
+// Example:
+//
+//	s := smdtest.NewServer(smdtest.DefaultFixtures())
+//	defer s.Close()
+//	params := generator.Params{
+//		ClientOpts:    []client.Option{client.WithHost(s.URL)},
+//		BssClientOpts: []client.Option{client.WithHost(s.URL)},
+//	}
+package smdtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+)
+
+// The inventory served by the fake SMD service.
+type Fixtures struct {
+	Components         []configurator.Component         `json:"Components"`
+	EthernetInterfaces []configurator.EthernetInterface `json:"EthernetInterfaces"`
+	RedfishEndpoints   []configurator.RedfishEndpoint   `json:"RedfishEndpoints"`
+	Hardware           []configurator.HardwareInventory `json:"Hardware"`
+	ComponentEndpoints []configurator.ComponentEndpoint `json:"ComponentEndpoints"`
+	ServiceEndpoints   []configurator.ServiceEndpoint   `json:"ServiceEndpoints"`
+	BootParameters     []configurator.BootParameters    `json:"BootParameters"`
+}
+
+// A fake SMD service that wraps a httptest.Server. The fixtures, errors, and
+// latency can be changed while the server is running.
+type Server struct {
+	*httptest.Server
+	mu       sync.RWMutex
+	fixtures Fixtures
+	errors   map[string]int
+	latency  time.Duration
+	requests map[string]int
+}
+
+// Creates and starts a new fake SMD service that serves the fixtures. The
+// server should be closed with Close() when done.
+func NewServer(fixtures Fixtures) *Server {
+	s := &Server{
+		fixtures: fixtures,
+		errors:   map[string]int{},
+		requests: map[string]int{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hsm/v2/State/Components", s.handle(func(f Fixtures) any {
+		return map[string]any{"Components": f.Components}
+	}))
+	mux.HandleFunc("/hsm/v2/Inventory/EthernetInterfaces", s.handle(func(f Fixtures) any {
+		return f.EthernetInterfaces
+	}))
+	mux.HandleFunc("/hsm/v2/Inventory/RedfishEndpoints", s.handle(func(f Fixtures) any {
+		return map[string]any{"RedfishEndpoints": f.RedfishEndpoints}
+	}))
+	mux.HandleFunc("/hsm/v2/Inventory/Hardware", s.handle(func(f Fixtures) any {
+		return f.Hardware
+	}))
+	mux.HandleFunc("/hsm/v2/Inventory/ComponentEndpoints", s.handle(func(f Fixtures) any {
+		return map[string]any{"ComponentEndpoints": f.ComponentEndpoints}
+	}))
+	mux.HandleFunc("/hsm/v2/Inventory/ServiceEndpoints", s.handle(func(f Fixtures) any {
+		return map[string]any{"ServiceEndpoints": f.ServiceEndpoints}
+	}))
+	mux.HandleFunc("/boot/v1/bootparameters", s.handle(func(f Fixtures) any {
+		return f.BootParameters
+	}))
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Loads the fixtures from a JSON file with the same structure as Fixtures.
+func LoadFixtures(path string) (Fixtures, error) {
+	var fixtures Fixtures
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fixtures, err
+	}
+	err = json.Unmarshal(b, &fixtures)
+	return fixtures, err
+}
+
+// Replaces the fixtures served by the server.
+func (s *Server) SetFixtures(fixtures Fixtures) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixtures = fixtures
+}
+
+// Makes the server respond with the status code for every request to the
+// endpoint (e.g. "/hsm/v2/Inventory/EthernetInterfaces"). A status code of
+// zero removes the error.
+func (s *Server) SetError(endpoint string, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if statusCode == 0 {
+		delete(s.errors, endpoint)
+		return
+	}
+	s.errors[endpoint] = statusCode
+}
+
+// Makes the server wait before responding to every request.
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// Returns the number of requests made to the endpoint.
+func (s *Server) Requests(endpoint string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.requests[endpoint]
+}
+
+func (s *Server) handle(body func(Fixtures) any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.TrimSuffix(r.URL.Path, "/")
+
+		s.mu.Lock()
+		s.requests[endpoint]++
+		var (
+			fixtures   = s.fixtures
+			statusCode = s.errors[endpoint]
+			latency    = s.latency
+		)
+		s.mu.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(latency):
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if statusCode != 0 {
+			w.WriteHeader(statusCode)
+			json.NewEncoder(w).Encode(map[string]any{
+				"type":   "about:blank",
+				"title":  http.StatusText(statusCode),
+				"status": statusCode,
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(body(fixtures))
+	}
+}
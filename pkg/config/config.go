@@ -1,8 +1,11 @@
 package config
 
 import (
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -17,30 +20,69 @@ type Jwks struct {
 }
 
 type Server struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port"`
-	Jwks Jwks   `yaml:"jwks,omitempty"`
+	Host     string        `yaml:"host"`
+	Port     int           `yaml:"port"`
+	Jwks     Jwks          `yaml:"jwks,omitempty"`
+	CacheTTL time.Duration `yaml:"cache-ttl,omitempty"`
+
+	// Directory where templates and targets created with the API are stored
+	Storage string `yaml:"storage,omitempty"`
+}
+
+// Settings used to create the clients that fetch data from services like SMD.
+type Client struct {
+	Host     string        `yaml:"host"`
+	CertPath string        `yaml:"cacert,omitempty"`
+	Timeout  time.Duration `yaml:"timeout,omitempty"`
+	Retries  int           `yaml:"retries,omitempty"`
+	Proxy    string        `yaml:"proxy,omitempty"`
+
+	// Skip verifying the service certificates (only for testing)
+	Insecure bool `yaml:"insecure,omitempty"`
+}
+
+// Settings for where previously applied files are kept to roll back to.
+type State struct {
+	Path string `yaml:"path"`
+	Keep int    `yaml:"keep,omitempty"`
 }
 
 type Config struct {
 	Version     string                         `yaml:"version,omitempty"`
 	Server      Server                         `yaml:"server,omitempty"`
-	SmdClient   client.SmdClient               `yaml:"smd,omitempty"`
+	SmdClient   Client                         `yaml:"smd,omitempty"`
+	BssClient   Client                         `yaml:"bss,omitempty"`
 	AccessToken string                         `yaml:"access-token,omitempty"`
 	Targets     map[string]configurator.Target `yaml:"targets,omitempty"`
 	PluginDirs  []string                       `yaml:"plugins,omitempty"`
 	CertPath    string                         `yaml:"cacert,omitempty"`
+	State       State                          `yaml:"state,omitempty"`
+
+	// Directories to find templates used with "include", "extends", and
+	// "import" in after the directories set for a target
+	TemplateDirs []string `yaml:"template-dirs,omitempty"`
+
+	// Return an error when templates use variables that are not defined
+	// instead of rendering them as empty values
+	Strict bool `yaml:"strict,omitempty"`
+
+	// Variables passed to the templates for every target
+	Vars map[string]any `yaml:"vars,omitempty"`
 }
 
 // Creates a new config with default parameters.
 func New() Config {
 	return Config{
 		Version:    "",
-		SmdClient:  client.SmdClient{Host: "http://127.0.0.1:27779"},
+		SmdClient:  Client{Host: "http://127.0.0.1:27779"},
+		BssClient:  Client{Host: "http://127.0.0.1:27778"},
 		Targets:    map[string]configurator.Target{},
 		PluginDirs: []string{},
+		State:      State{Path: "/var/lib/configurator", Keep: 5},
 		Server: Server{
-			Host: "127.0.0.1:3334",
+			Host:     "127.0.0.1:3334",
+			CacheTTL: 30 * time.Second,
+			Storage:  "/var/lib/configurator-server",
 			Jwks: Jwks{
 				Uri:     "",
 				Retries: 5,
@@ -49,6 +91,84 @@ func New() Config {
 	}
 }
 
+// Returns the directories to find templates in for the target. The
+// directories set for the target are used before the global ones.
+func (config *Config) TemplateSearchPath(target string) []string {
+	searchPath := []string{}
+	searchPath = append(searchPath, config.Targets[target].TemplateDirs...)
+	return append(searchPath, config.TemplateDirs...)
+}
+
+// Returns the variables passed to the target's templates. Variables set for
+// the target replace global variables with the same name.
+func (config *Config) TemplateVars(target string) map[string]any {
+	vars := make(map[string]any, len(config.Vars))
+	maps.Copy(vars, config.Vars)
+	maps.Copy(vars, config.Targets[target].Vars)
+	return vars
+}
+
+// Converts the nested maps decoded from YAML with keys of any type to maps
+// with string keys so that templates can use them and they can be marshalled
+// to JSON.
+func normalizeVars(vars map[string]any) map[string]any {
+	for key, value := range vars {
+		vars[key] = normalizeValue(value)
+	}
+	return vars
+}
+
+func normalizeValue(value any) any {
+	switch v := value.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalizeValue(value)
+		}
+		return m
+	case []any:
+		for i := range v {
+			v[i] = normalizeValue(v[i])
+		}
+		return v
+	}
+	return value
+}
+
+// Returns the options used to create a client for SMD. This should be the only
+// place where the client options are set from the config so that the CLI and
+// server create clients the same way. The "smd.cacert" is used if set or
+// falls back to the global "cacert" otherwise.
+func (config *Config) SmdClientOptions() []client.Option {
+	return config.SmdClient.options(config.AccessToken, config.CertPath)
+}
+
+// Returns the options used to create a client for BSS in the same way as
+// SmdClientOptions().
+func (config *Config) BssClientOptions() []client.Option {
+	return config.BssClient.options(config.AccessToken, config.CertPath)
+}
+
+func (c Client) options(accessToken string, certPath string) []client.Option {
+	opts := []client.Option{
+		client.WithHost(c.Host),
+		client.WithAccessToken(accessToken),
+		client.WithProxy(c.Proxy),
+		client.WithInsecure(c.Insecure),
+	}
+	if c.CertPath != "" {
+		certPath = c.CertPath
+	}
+	opts = append(opts, client.WithCertPoolFile(certPath))
+	if c.Timeout > 0 {
+		opts = append(opts, client.WithTimeout(c.Timeout))
+	}
+	if c.Retries > 0 {
+		opts = append(opts, client.WithRetries(c.Retries, client.DefaultRetryWait))
+	}
+	return opts
+}
+
 func Load(path string) Config {
 	var c Config = New()
 	file, err := os.ReadFile(path)
@@ -61,6 +181,13 @@ func Load(path string) Config {
 		log.Error().Err(err).Msg("failed to unmarshal config")
 		return c
 	}
+
+	// nested maps in vars are decoded with keys of any type
+	c.Vars = normalizeVars(c.Vars)
+	for name, target := range c.Targets {
+		target.Vars = normalizeVars(target.Vars)
+		c.Targets[name] = target
+	}
 	return c
 }
 
@@ -1,12 +1,75 @@
 package configurator
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
 
 type Target struct {
-	Plugin        string   `yaml:"plugin,omitempty"`    // Set the plugin or it's path
-	TemplatePaths []string `yaml:"templates,omitempty"` // Set the template paths
-	FilePaths     []string `yaml:"files,omitempty"`     // Set the file paths
-	RunTargets    []string `yaml:"targets,omitempty"`   // Set additional targets to run
+	Plugin        string   `yaml:"plugin,omitempty"`        // Set the plugin or it's path
+	TemplatePaths []string `yaml:"templates,omitempty"`     // Set the template paths
+	FilePaths     []string `yaml:"files,omitempty"`         // Set the file paths
+	RunTargets    []string `yaml:"targets,omitempty"`       // Set additional targets to run
+	Mode          string   `yaml:"mode,omitempty"`          // Set the octal file mode of applied files (e.g. "0644")
+	Owner         string   `yaml:"owner,omitempty"`         // Set the user name or ID that owns applied files
+	Group         string   `yaml:"group,omitempty"`         // Set the group name or ID that owns applied files
+	Hooks         []string `yaml:"hooks,omitempty"`         // Set commands to run after applied files change
+	TemplateDirs  []string `yaml:"template-dirs,omitempty"` // Set directories to find included templates in
+	Engine        string   `yaml:"engine,omitempty"`        // Set the template engine ("jinja" or "gotmpl") instead of using the extension
+
+	// Set where the output of each template is written keyed by template path
+	Outputs map[string]Output `yaml:"outputs,omitempty"`
+
+	// Set variables passed to the target's templates (overrides global 'vars')
+	Vars map[string]any `yaml:"vars,omitempty"`
+}
+
+// Where the output of a template is written relative to the output path. The
+// path can use Jinja templating with the same mappings as the template. If
+// ForEach is set to the name of a list in the mappings, the template is
+// rendered once for each item with the item's fields added to the mappings.
+//
+// An output can be set in the config with just the path:
+//
+//	outputs:
+//	  templates/dnsmasq.jinja: dnsmasq.d/openchami.conf
+//	  templates/hosts.jinja:
+//	    path: "{{ xname }}/hosts"
+//	    foreach: boot_entries
+type Output struct {
+	Path    string `yaml:"path" json:"path"`
+	ForEach string `yaml:"foreach,omitempty" json:"foreach,omitempty"`
+}
+
+func (o *Output) UnmarshalYAML(unmarshal func(any) error) error {
+	if err := unmarshal(&o.Path); err == nil {
+		return nil
+	}
+	type output Output
+	return unmarshal((*output)(o))
+}
+
+func (o *Output) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &o.Path); err == nil {
+		return nil
+	}
+	type output Output
+	return json.Unmarshal(b, (*output)(o))
+}
+
+// Returns the file mode set for the target. If no mode is set, the fallback
+// mode is returned instead.
+func (t Target) FileMode(fallback os.FileMode) (os.FileMode, error) {
+	if t.Mode == "" {
+		return fallback, nil
+	}
+	mode, err := strconv.ParseUint(t.Mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file mode '%s': %v", t.Mode, err)
+	}
+	return os.FileMode(mode).Perm(), nil
 }
 
 type IPAddr struct {
@@ -59,6 +122,112 @@ type RedfishEndpoint struct {
 	IPAddr      string `json:"IPAddress,omitempty"`
 }
 
+type HardwareInventory struct {
+	ID                        string                `json:"ID"`
+	Type                      string                `json:"Type"`
+	Ordinal                   int                   `json:"Ordinal"`
+	Status                    string                `json:"Status"`
+	HWInventoryByLocationType string                `json:"HWInventoryByLocationType"`
+	NodeLocationInfo          *NodeLocationInfo     `json:"NodeLocationInfo,omitempty"`
+	ProcessorLocationInfo     *LocationInfo         `json:"ProcessorLocationInfo,omitempty"`
+	MemoryLocationInfo        *LocationInfo         `json:"MemoryLocationInfo,omitempty"`
+	PopulatedFRU              *HardwareInventoryFRU `json:"PopulatedFRU,omitempty"`
+}
+
+type LocationInfo struct {
+	Id          string `json:"Id"`
+	Name        string `json:"Name,omitempty"`
+	Description string `json:"Description,omitempty"`
+	Socket      string `json:"Socket,omitempty"`
+}
+
+type NodeLocationInfo struct {
+	Id               string           `json:"Id"`
+	Name             string           `json:"Name,omitempty"`
+	Description      string           `json:"Description,omitempty"`
+	Hostname         string           `json:"Hostname,omitempty"`
+	ProcessorSummary ProcessorSummary `json:"ProcessorSummary,omitempty"`
+	MemorySummary    MemorySummary    `json:"MemorySummary,omitempty"`
+}
+
+type ProcessorSummary struct {
+	Count int    `json:"Count,omitempty"`
+	Model string `json:"Model,omitempty"`
+}
+
+type MemorySummary struct {
+	TotalSystemMemoryGiB float64 `json:"TotalSystemMemoryGiB,omitempty"`
+}
+
+type HardwareInventoryFRU struct {
+	FRUID                string         `json:"FRUID"`
+	Type                 string         `json:"Type"`
+	Subtype              string         `json:"Subtype,omitempty"`
+	HWInventoryByFRUType string         `json:"HWInventoryByFRUType"`
+	ProcessorFRUInfo     *ProcessorInfo `json:"ProcessorFRUInfo,omitempty"`
+	MemoryFRUInfo        *MemoryInfo    `json:"MemoryFRUInfo,omitempty"`
+}
+
+type ProcessorInfo struct {
+	Manufacturer string `json:"Manufacturer,omitempty"`
+	Model        string `json:"Model,omitempty"`
+	TotalCores   int    `json:"TotalCores,omitempty"`
+	TotalThreads int    `json:"TotalThreads,omitempty"`
+	MaxSpeedMHz  int    `json:"MaxSpeedMHz,omitempty"`
+}
+
+type MemoryInfo struct {
+	Manufacturer      string `json:"Manufacturer,omitempty"`
+	MemoryType        string `json:"MemoryType,omitempty"`
+	CapacityMiB       int    `json:"CapacityMiB,omitempty"`
+	OperatingSpeedMhz int    `json:"OperatingSpeedMhz,omitempty"`
+}
+
+type ComponentEndpoint struct {
+	ID                    string             `json:"ID"`
+	Type                  string             `json:"Type"`
+	Domain                string             `json:"Domain,omitempty"`
+	FQDN                  string             `json:"FQDN,omitempty"`
+	RedfishType           string             `json:"RedfishType"`
+	RedfishSubtype        string             `json:"RedfishSubtype,omitempty"`
+	MACAddr               string             `json:"MACAddr,omitempty"`
+	UUID                  string             `json:"UUID,omitempty"`
+	OdataID               string             `json:"OdataID"`
+	RedfishEndpointID     string             `json:"RedfishEndpointID"`
+	Enabled               bool               `json:"Enabled"`
+	RedfishEndpointFQDN   string             `json:"RedfishEndpointFQDN"`
+	RedfishURL            string             `json:"RedfishURL"`
+	ComponentEndpointType string             `json:"ComponentEndpointType"`
+	RedfishSystemInfo     *RedfishSystemInfo `json:"RedfishSystemInfo,omitempty"`
+}
+
+type RedfishSystemInfo struct {
+	Name         string `json:"Name,omitempty"`
+	PowerURL     string `json:"PowerURL,omitempty"`
+	PowerControl []any  `json:"PowerControl,omitempty"`
+}
+
+type ServiceEndpoint struct {
+	RedfishEndpointID   string         `json:"RedfishEndpointID"`
+	RedfishType         string         `json:"RedfishType"`
+	RedfishSubtype      string         `json:"RedfishSubtype,omitempty"`
+	UUID                string         `json:"UUID,omitempty"`
+	OdataID             string         `json:"OdataID"`
+	RedfishEndpointFQDN string         `json:"RedfishEndpointFQDN"`
+	RedfishURL          string         `json:"RedfishURL"`
+	ServiceInfo         map[string]any `json:"ServiceInfo,omitempty"`
+}
+
+type BootParameters struct {
+	Macs      []string       `json:"macs,omitempty"`
+	Hosts     []string       `json:"hosts,omitempty"`
+	Nids      []int32        `json:"nids,omitempty"`
+	Params    string         `json:"params,omitempty"`
+	Kernel    string         `json:"kernel,omitempty"`
+	Initrd    string         `json:"initrd,omitempty"`
+	CloudInit map[string]any `json:"cloud-init,omitempty"`
+}
+
 type Node struct {
 }
 
@@ -0,0 +1,146 @@
+package generator
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/client"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/util"
+)
+
+type BootParams struct{}
+
+// A single node's boot entry created by joining the boot parameters from BSS
+// with the ethernet interfaces from SMD by MAC address.
+type BootEntry struct {
+	Xname  string `json:"xname"`
+	MAC    string `json:"mac"`
+	IP     string `json:"ip"`
+	Kernel string `json:"kernel"`
+	Initrd string `json:"initrd"`
+	Params string `json:"params"`
+
+	// Unique name for the entry made from the xname and MAC address since a
+	// node with more than one interface has an entry for each of them. Used
+	// as the iPXE label.
+	Label string `json:"label"`
+}
+
+func (g *BootParams) GetName() string {
+	return "bootparams"
+}
+
+func (g *BootParams) GetVersion() string {
+	return util.GitCommit()
+}
+
+func (g *BootParams) GetDescription() string {
+	return fmt.Sprintf("Configurator generator plugin for '%s' to generate iPXE or GRUB boot entries.", g.GetName())
+}
+
+// Returns the variables passed to templates.
+func (g *BootParams) GetVariables() []string {
+	return slices.Concat(pluginVariables, []string{"boot_entries", "ipxe_entries", "grub_entries"}, smdVariables)
+}
+
+func (g *BootParams) Generate(config *config.Config, params Params) (FileMap, error) {
+	var (
+		ipxeEntries = ""
+		ipxeLabels  = ""
+		grubEntries = ""
+	)
+
+	// create the clients first so that invalid options are returned
+	smdClient, err := client.NewSmdClient(params.ClientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	bssClient, err := client.NewBssClient(params.BssClientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// fetch the boot parameters from BSS and interfaces from SMD to join
+	bootParams, err := bssClient.FetchBootParameters(params.GetContext(), params.Verbose)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch boot parameters with client: %v", err)
+	}
+	inventory, err := FetchSmdInventory(&smdClient, params, "interfaces")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch inventory with client: %v", err)
+	}
+	entries := JoinBootParameters(bootParams, inventory.Interfaces)
+
+	// format output to write to config file
+	ipxeEntries = "# ========== DYNAMICALLY GENERATED BY OPENCHAMI CONFIGURATOR ==========\n"
+	grubEntries = "# ========== DYNAMICALLY GENERATED BY OPENCHAMI CONFIGURATOR ==========\n"
+	for _, entry := range entries {
+		ipxeEntries += fmt.Sprintf("iseq ${net0/mac} %s && goto %s ||\n", entry.MAC, entry.Label)
+		ipxeLabels += fmt.Sprintf(":%s\nkernel %s %s\ninitrd %s\nboot\n\n", entry.Label, entry.Kernel, entry.Params, entry.Initrd)
+		grubEntries += fmt.Sprintf("menuentry \"%s\" {\n    linux %s %s\n    initrd %s\n}\n", entry.Xname, entry.Kernel, entry.Params, entry.Initrd)
+	}
+	ipxeEntries += "exit\n\n" + ipxeLabels
+	ipxeEntries += "# ====================================================================="
+	grubEntries += "# ====================================================================="
+
+	// apply template substitutions and return output as byte array
+	mappings := Mappings{
+		"plugin_name":        g.GetName(),
+		"plugin_version":     g.GetVersion(),
+		"plugin_description": g.GetDescription(),
+		"boot_entries":       entries,
+		"ipxe_entries":       ipxeEntries,
+		"grub_entries":       grubEntries,
+	}
+	maps.Copy(mappings, inventory.Mappings())
+	return ApplyTemplates(mappings, params.Templates)
+}
+
+// Joins the boot parameters from BSS with the ethernet interfaces from SMD by
+// MAC address to create a boot entry for each interface. Boot parameters that
+// are set by host (xname) instead of MAC address are joined using each of the
+// interfaces that belong to the component. Interfaces without boot parameters
+// are skipped.
+func JoinBootParameters(bootParams []configurator.BootParameters, eths []configurator.EthernetInterface) []BootEntry {
+	var (
+		byMAC   = map[string]configurator.BootParameters{}
+		byXname = map[string]configurator.BootParameters{}
+		entries = []BootEntry{}
+	)
+	for _, bp := range bootParams {
+		for _, mac := range bp.Macs {
+			byMAC[strings.ToLower(mac)] = bp
+		}
+		for _, host := range bp.Hosts {
+			byXname[host] = bp
+		}
+	}
+
+	for _, eth := range eths {
+		// parameters set by MAC take precedence over the ones set by host
+		bp, ok := byMAC[strings.ToLower(eth.MacAddress)]
+		if !ok {
+			bp, ok = byXname[eth.ComponentId]
+			if !ok {
+				continue
+			}
+		}
+		entry := BootEntry{
+			Xname:  eth.ComponentId,
+			MAC:    strings.ToLower(eth.MacAddress),
+			Kernel: bp.Kernel,
+			Initrd: bp.Initrd,
+			Params: bp.Params,
+		}
+		entry.Label = entry.Xname + "-" + strings.ReplaceAll(entry.MAC, ":", "")
+		if len(eth.IpAddresses) > 0 {
+			entry.IP = eth.IpAddresses[0].IpAddress
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
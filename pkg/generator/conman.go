@@ -2,6 +2,8 @@ package generator
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 
 	configurator "github.com/OpenCHAMI/configurator/pkg"
 	"github.com/OpenCHAMI/configurator/pkg/client"
@@ -23,19 +25,30 @@ func (g *Conman) GetDescription() string {
 	return fmt.Sprintf("Configurator generator plugin for '%s'.", g.GetName())
 }
 
+// Returns the variables passed to templates.
+func (g *Conman) GetVariables() []string {
+	return slices.Concat(pluginVariables, []string{"server_opts", "global_opts", "consoles"}, smdVariables)
+}
+
 func (g *Conman) Generate(config *config.Config, params Params) (FileMap, error) {
 	var (
-		smdClient       = client.NewSmdClient(params.ClientOpts...)
-		eps             = []configurator.RedfishEndpoint{}
-		err       error = nil
-		consoles        = ""
+		eps            = []configurator.RedfishEndpoint{}
+		err      error = nil
+		consoles       = ""
 	)
 
+	// create the client first so that invalid options are returned
+	smdClient, err := client.NewSmdClient(params.ClientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
 	// fetch required data from SMD to create config
-	eps, err = smdClient.FetchRedfishEndpoints(params.Verbose)
+	inventory, err := FetchSmdInventory(&smdClient, params, "redfish_endpoints")
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch redfish endpoints with client: %v", err)
+		return nil, fmt.Errorf("failed to fetch inventory with client: %v", err)
 	}
+	eps = inventory.RedfishEndpoints
 
 	// format output to write to config file
 	consoles = "# ========== DYNAMICALLY GENERATED BY OPENCHAMI CONFIGURATOR ==========\n"
@@ -45,12 +58,14 @@ func (g *Conman) Generate(config *config.Config, params Params) (FileMap, error)
 	consoles += "# ====================================================================="
 
 	// apply template substitutions and return output as byte array
-	return ApplyTemplates(Mappings{
+	mappings := Mappings{
 		"plugin_name":        g.GetName(),
 		"plugin_version":     g.GetVersion(),
 		"plugin_description": g.GetDescription(),
 		"server_opts":        "",
 		"global_opts":        "",
 		"consoles":           consoles,
-	}, params.Templates)
+	}
+	maps.Copy(mappings, inventory.Mappings())
+	return ApplyTemplates(mappings, params.Templates)
 }
@@ -2,6 +2,8 @@ package generator
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 
 	configurator "github.com/OpenCHAMI/configurator/pkg"
 	"github.com/OpenCHAMI/configurator/pkg/client"
@@ -23,19 +25,30 @@ func (g *DHCPd) GetDescription() string {
 	return fmt.Sprintf("Configurator generator plugin for '%s'.", g.GetName())
 }
 
+// Returns the variables passed to templates.
+func (g *DHCPd) GetVariables() []string {
+	return slices.Concat(pluginVariables, []string{"compute_nodes", "node_entries", "subnet", "boot_server"}, smdVariables)
+}
+
 func (g *DHCPd) Generate(config *config.Config, params Params) (FileMap, error) {
 	var (
-		smdClient          = client.NewSmdClient(params.ClientOpts...)
 		eths               = []configurator.EthernetInterface{}
 		computeNodes       = ""
 		err          error = nil
 	)
 
+	// create the client first so that invalid options are returned
+	smdClient, err := client.NewSmdClient(params.ClientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
 	//
-	eths, err = smdClient.FetchEthernetInterfaces(params.Verbose)
+	inventory, err := FetchSmdInventory(&smdClient, params, "interfaces")
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch ethernet interfaces with client: %w", err)
+		return nil, fmt.Errorf("failed to fetch inventory with client: %w", err)
 	}
+	eths = inventory.Interfaces
 
 	// check if we have the required params first
 	if eths == nil {
@@ -51,14 +64,18 @@ func (g *DHCPd) Generate(config *config.Config, params Params) (FileMap, error)
 		if len(eth.IpAddresses) == 0 {
 			continue
 		}
-		computeNodes += fmt.Sprintf("host %s { hardware ethernet %s; fixed-address %s} ", eth.ComponentId, eth.MacAddress, eth.IpAddresses[0])
+		computeNodes += fmt.Sprintf("host %s { hardware ethernet %s; fixed-address %s; }\n", eth.ComponentId, eth.MacAddress, eth.IpAddresses[0].IpAddress)
 	}
 	computeNodes += "# ====================================================================="
-	return ApplyTemplates(Mappings{
+	mappings := Mappings{
 		"plugin_name":        g.GetName(),
 		"plugin_version":     g.GetVersion(),
 		"plugin_description": g.GetDescription(),
 		"compute_nodes":      computeNodes,
 		"node_entries":       "",
-	}, params.Templates)
+		"subnet":             "",
+		"boot_server":        "",
+	}
+	maps.Copy(mappings, inventory.Mappings())
+	return ApplyTemplates(mappings, params.Templates)
 }
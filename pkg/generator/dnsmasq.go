@@ -2,6 +2,8 @@ package generator
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 
 	configurator "github.com/OpenCHAMI/configurator/pkg"
 	"github.com/OpenCHAMI/configurator/pkg/client"
@@ -23,6 +25,11 @@ func (g *DNSMasq) GetDescription() string {
 	return fmt.Sprintf("Configurator generator plugin for '%s'.", g.GetName())
 }
 
+// Returns the variables passed to templates.
+func (g *DNSMasq) GetVariables() []string {
+	return slices.Concat(pluginVariables, []string{"dhcp_hosts"}, smdVariables)
+}
+
 func (g *DNSMasq) Generate(config *config.Config, params Params) (FileMap, error) {
 	// make sure we have a valid config first
 	if config == nil {
@@ -31,16 +38,22 @@ func (g *DNSMasq) Generate(config *config.Config, params Params) (FileMap, error
 
 	// set all the defaults for variables
 	var (
-		smdClient       = client.NewSmdClient(params.ClientOpts...)
-		eths            = []configurator.EthernetInterface{}
-		err       error = nil
+		eths       = []configurator.EthernetInterface{}
+		err  error = nil
 	)
 
+	// create the client first so that invalid options are returned
+	smdClient, err := client.NewSmdClient(params.ClientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
 	// if we have a client, try making the request for the ethernet interfaces
-	eths, err = smdClient.FetchEthernetInterfaces(params.Verbose)
+	inventory, err := FetchSmdInventory(&smdClient, params, "interfaces")
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch ethernet interfaces with client: %v", err)
+		return nil, fmt.Errorf("failed to fetch inventory with client: %v", err)
 	}
+	eths = inventory.Interfaces
 
 	// check if we have the required params first
 	if eths == nil {
@@ -62,10 +75,12 @@ func (g *DNSMasq) Generate(config *config.Config, params Params) (FileMap, error
 	output += "# ====================================================================="
 
 	// apply template substitutions and return output as byte array
-	return ApplyTemplates(Mappings{
+	mappings := Mappings{
 		"plugin_name":        g.GetName(),
 		"plugin_version":     g.GetVersion(),
 		"plugin_description": g.GetDescription(),
 		"dhcp_hosts":         output,
-	}, params.Templates)
+	}
+	maps.Copy(mappings, inventory.Mappings())
+	return ApplyTemplates(mappings, params.Templates)
 }
@@ -0,0 +1,491 @@
+package generator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/OpenCHAMI/configurator/pkg/xname"
+	"github.com/nikolalohinski/gonja/v2/exec"
+)
+
+// Custom Jinja filter that is available in every template rendered with
+// ApplyTemplates along with the built-in gonja filters.
+type Filter struct {
+	Name        string
+	Usage       string
+	Description string
+	Function    exec.FilterFunction
+}
+
+// Filters for working with IP addresses, CIDRs, MACs, and xnames in templates
+// keyed by the filter name.
+var DefaultFilters = createDefaultFilters()
+
+func createDefaultFilters() map[string]Filter {
+	var (
+		filterMap = map[string]Filter{}
+		filters   = []Filter{
+			{
+				Name:        "ip_network",
+				Usage:       "{{ '172.16.0.5/24' | ip_network }} -> 172.16.0.0/24",
+				Description: "Returns the network of a CIDR with the host bits cleared.",
+				Function:    filterIPNetwork,
+			},
+			{
+				Name:        "ip_netmask",
+				Usage:       "{{ '172.16.0.5/24' | ip_netmask }} -> 255.255.255.0",
+				Description: "Returns the netmask of a CIDR.",
+				Function:    filterIPNetmask,
+			},
+			{
+				Name:        "ip_prefix",
+				Usage:       "{{ '172.16.0.5/24' | ip_prefix }} -> 24",
+				Description: "Returns the prefix length of a CIDR.",
+				Function:    filterIPPrefix,
+			},
+			{
+				Name:        "ip_reverse",
+				Usage:       "{{ '172.16.0.5' | ip_reverse }} -> 5.0.16.172.in-addr.arpa",
+				Description: "Returns the reverse DNS name of an IP or the reverse zone of a CIDR.",
+				Function:    filterIPReverse,
+			},
+			{
+				Name:        "ip_nth",
+				Usage:       "{{ '172.16.0.0/24' | ip_nth(10) }} -> 172.16.0.10",
+				Description: "Returns the nth address in a CIDR. Negative numbers count back from the last address.",
+				Function:    filterIPNth,
+			},
+			{
+				Name:        "mac_format",
+				Usage:       "{{ 'A4BF0138EE66' | mac_format('-') }} -> a4-bf-01-38-ee-66",
+				Description: "Returns a MAC in lowercase with the separator (defaults to ':' and '.' groups by 4).",
+				Function:    filterMACFormat,
+			},
+			{
+				Name:        "mac_to_pxelinux",
+				Usage:       "{{ 'a4:bf:01:38:ee:66' | mac_to_pxelinux }} -> 01-a4-bf-01-38-ee-66",
+				Description: "Returns the pxelinux.cfg file name for a MAC.",
+				Function:    filterMACToPxelinux,
+			},
+			{
+				Name:        "xname_type",
+				Usage:       "{{ 'x1000c0s7b0n1' | xname_type }} -> Node",
+				Description: "Returns the component type of an xname using the same names as SMD.",
+				Function:    filterXNameType,
+			},
+			{
+				Name:        "xname_parent",
+				Usage:       "{{ 'x1000c0s7b0n1' | xname_parent }} -> x1000c0s7b0",
+				Description: "Returns the xname one level up (e.g. node -> BMC -> slot).",
+				Function:    filterXNameParent,
+			},
+			{
+				Name:        "xname_cabinet",
+				Usage:       "{{ 'x1000c0s7b0n1' | xname_cabinet }} -> x1000",
+				Description: "Returns the xname of the cabinet that contains an xname.",
+				Function:    xnameAncestorFilter("xname_cabinet", xname.Cabinet),
+			},
+			{
+				Name:        "xname_chassis",
+				Usage:       "{{ 'x1000c0s7b0n1' | xname_chassis }} -> x1000c0",
+				Description: "Returns the xname of the chassis that contains an xname.",
+				Function:    xnameAncestorFilter("xname_chassis", xname.Chassis),
+			},
+			{
+				Name:        "xname_slot",
+				Usage:       "{{ 'x1000c0s7b0n1' | xname_slot }} -> x1000c0s7",
+				Description: "Returns the xname of the slot that contains an xname.",
+				Function:    xnameAncestorFilter("xname_slot", xname.Slot),
+			},
+			{
+				Name:        "xname_bmc",
+				Usage:       "{{ 'x1000c0s7b0n1' | xname_bmc }} -> x1000c0s7b0",
+				Description: "Returns the xname of the BMC that manages a node.",
+				Function:    xnameAncestorFilter("xname_bmc", xname.BMC),
+			},
+			{
+				Name:        "xname_sort",
+				Usage:       "{% for c in components | xname_sort(attribute='ID') %}",
+				Description: "Sorts a list of xnames or items with an xname attribute in physical order.",
+				Function:    filterXNameSort,
+			},
+		}
+	)
+	for _, f := range filters {
+		filterMap[f.Name] = f
+	}
+	return filterMap
+}
+
+// Returns the filters as a set that can be added to a gonja environment.
+func filterSet(filters map[string]Filter) *exec.FilterSet {
+	functions := make(map[string]exec.FilterFunction, len(filters))
+	for name, f := range filters {
+		functions[name] = f.Function
+	}
+	return exec.NewFilterSet(functions)
+}
+
+func filterIPNetwork(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	if p := params.ExpectNothing(); p.IsError() {
+		return exec.AsValue(fmt.Errorf("wrong signature for 'ip_network': %s", p.Error()))
+	}
+	network, err := ipNetwork(in.String())
+	if err != nil {
+		return exec.AsValue(fmt.Errorf("ip_network: %v", err))
+	}
+	return exec.AsValue(network)
+}
+
+func filterIPNetmask(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	if p := params.ExpectNothing(); p.IsError() {
+		return exec.AsValue(fmt.Errorf("wrong signature for 'ip_netmask': %s", p.Error()))
+	}
+	netmask, err := ipNetmask(in.String())
+	if err != nil {
+		return exec.AsValue(fmt.Errorf("ip_netmask: %v", err))
+	}
+	return exec.AsValue(netmask)
+}
+
+func filterIPPrefix(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	if p := params.ExpectNothing(); p.IsError() {
+		return exec.AsValue(fmt.Errorf("wrong signature for 'ip_prefix': %s", p.Error()))
+	}
+	bits, err := ipPrefix(in.String())
+	if err != nil {
+		return exec.AsValue(fmt.Errorf("ip_prefix: %v", err))
+	}
+	return exec.AsValue(bits)
+}
+
+func filterIPReverse(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	if p := params.ExpectNothing(); p.IsError() {
+		return exec.AsValue(fmt.Errorf("wrong signature for 'ip_reverse': %s", p.Error()))
+	}
+	name, err := reverseName(in.String())
+	if err != nil {
+		return exec.AsValue(fmt.Errorf("ip_reverse: %v", err))
+	}
+	return exec.AsValue(name)
+}
+
+func filterIPNth(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	p := params.ExpectArgs(1)
+	if p.IsError() || !p.First().IsInteger() {
+		return exec.AsValue(fmt.Errorf("wrong signature for 'ip_nth': expected an integer"))
+	}
+	addr, err := nthAddr(in.String(), p.First().Integer())
+	if err != nil {
+		return exec.AsValue(fmt.Errorf("ip_nth: %v", err))
+	}
+	return exec.AsValue(addr)
+}
+
+func filterMACFormat(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	p := params.ExpectKwArgs([]*exec.KwArg{{Name: "separator", Default: ":"}})
+	if p.IsError() {
+		return exec.AsValue(fmt.Errorf("wrong signature for 'mac_format': %s", p.Error()))
+	}
+	mac, err := parseMAC(in.String())
+	if err != nil {
+		return exec.AsValue(fmt.Errorf("mac_format: %v", err))
+	}
+	return exec.AsValue(formatMAC(mac, p.KwArgs["separator"].String()))
+}
+
+func filterMACToPxelinux(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	if p := params.ExpectNothing(); p.IsError() {
+		return exec.AsValue(fmt.Errorf("wrong signature for 'mac_to_pxelinux': %s", p.Error()))
+	}
+	name, err := macToPxelinux(in.String())
+	if err != nil {
+		return exec.AsValue(fmt.Errorf("mac_to_pxelinux: %v", err))
+	}
+	return exec.AsValue(name)
+}
+
+// Returns the network of the CIDR with the host bits cleared.
+func ipNetwork(cidr string) (string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", err
+	}
+	return prefix.Masked().String(), nil
+}
+
+// Returns the netmask of the CIDR in dotted form for IPv4.
+func ipNetmask(cidr string) (string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", err
+	}
+	mask := net.CIDRMask(prefix.Bits(), prefix.Addr().BitLen())
+	return net.IP(mask).String(), nil
+}
+
+// Returns the prefix length of the CIDR.
+func ipPrefix(cidr string) (int, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return 0, err
+	}
+	return prefix.Bits(), nil
+}
+
+// Returns the pxelinux.cfg file name for the MAC.
+func macToPxelinux(s string) (string, error) {
+	mac, err := parseMAC(s)
+	if err != nil {
+		return "", err
+	}
+	// the ARP hardware type for ethernet is prepended to the MAC
+	return "01-" + formatMAC(mac, "-"), nil
+}
+
+// Returns the reverse DNS name for an IP address or the reverse zone for a
+// CIDR. The zone only includes the octets (or nibbles for IPv6) that are
+// fully covered by the prefix.
+func reverseName(s string) (string, error) {
+	var (
+		addr  netip.Addr
+		parts []string
+		err   error
+	)
+	bits := -1
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return "", err
+		}
+		addr, bits = prefix.Masked().Addr(), prefix.Bits()
+	} else if addr, err = netip.ParseAddr(s); err != nil {
+		return "", err
+	}
+
+	if addr.Is4() {
+		octets := addr.As4()
+		count := 4
+		if bits >= 0 {
+			count = bits / 8
+		}
+		for i := count - 1; i >= 0; i-- {
+			parts = append(parts, fmt.Sprint(octets[i]))
+		}
+		return strings.Join(append(parts, "in-addr.arpa"), "."), nil
+	}
+	nibbles := hex.EncodeToString(addr.AsSlice())
+	count := len(nibbles)
+	if bits >= 0 {
+		count = bits / 4
+	}
+	for i := count - 1; i >= 0; i-- {
+		parts = append(parts, string(nibbles[i]))
+	}
+	return strings.Join(append(parts, "ip6.arpa"), "."), nil
+}
+
+// Returns the nth address in the CIDR starting at the network address. A
+// negative n counts back from the last address in the CIDR.
+func nthAddr(cidr string, n int) (string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", err
+	}
+	var (
+		network = new(big.Int).SetBytes(prefix.Masked().Addr().AsSlice())
+		size    = new(big.Int).Lsh(big.NewInt(1), uint(prefix.Addr().BitLen()-prefix.Bits()))
+		offset  = big.NewInt(int64(n))
+	)
+	if n < 0 {
+		offset.Add(offset, size)
+	}
+	if offset.Sign() < 0 || offset.Cmp(size) >= 0 {
+		return "", fmt.Errorf("index %d is out of range for '%s'", n, cidr)
+	}
+	b := network.Add(network, offset).FillBytes(make([]byte, prefix.Addr().BitLen()/8))
+	addr, _ := netip.AddrFromSlice(b)
+	return addr.String(), nil
+}
+
+// Parses a MAC with any of the common separators or with none at all.
+func parseMAC(s string) (net.HardwareAddr, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 12 {
+		if b, err := hex.DecodeString(s); err == nil {
+			return net.HardwareAddr(b), nil
+		}
+	}
+	return net.ParseMAC(s)
+}
+
+// Formats the MAC in lowercase with the separator between each byte. The "."
+// separator groups the MAC by 4 digits instead (e.g. a4bf.0138.ee66).
+func formatMAC(mac net.HardwareAddr, separator string) string {
+	var (
+		digits = hex.EncodeToString(mac)
+		size   = 2
+		groups = []string{}
+	)
+	if separator == "." {
+		size = 4
+	}
+	for i := 0; i < len(digits); i += size {
+		groups = append(groups, digits[i:min(i+size, len(digits))])
+	}
+	return strings.Join(groups, separator)
+}
+
+func filterXNameType(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	if p := params.ExpectNothing(); p.IsError() {
+		return exec.AsValue(fmt.Errorf("wrong signature for 'xname_type': %s", p.Error()))
+	}
+	t, err := xnameType(in.String())
+	if err != nil {
+		return exec.AsValue(fmt.Errorf("xname_type: %v", err))
+	}
+	return exec.AsValue(t)
+}
+
+func filterXNameParent(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	if p := params.ExpectNothing(); p.IsError() {
+		return exec.AsValue(fmt.Errorf("wrong signature for 'xname_parent': %s", p.Error()))
+	}
+	parent, err := xnameParent(in.String())
+	if err != nil {
+		return exec.AsValue(fmt.Errorf("xname_parent: %v", err))
+	}
+	return exec.AsValue(parent)
+}
+
+// Returns a filter that converts an xname to the xname of the component
+// with the type that contains it.
+func xnameAncestorFilter(name string, t xname.Type) exec.FilterFunction {
+	return func(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+		if in.IsError() {
+			return in
+		}
+		if p := params.ExpectNothing(); p.IsError() {
+			return exec.AsValue(fmt.Errorf("wrong signature for '%s': %s", name, p.Error()))
+		}
+		ancestor, err := xnameAncestor(in.String(), t)
+		if err != nil {
+			return exec.AsValue(fmt.Errorf("%s: %v", name, err))
+		}
+		return exec.AsValue(ancestor)
+	}
+}
+
+func filterXNameSort(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	p := params.ExpectKwArgs([]*exec.KwArg{{Name: "attribute", Default: ""}})
+	if p.IsError() {
+		return exec.AsValue(fmt.Errorf("wrong signature for 'xname_sort': %s", p.Error()))
+	}
+	if !in.IsList() {
+		return exec.AsValue(fmt.Errorf("xname_sort: expected a list"))
+	}
+
+	// sort the items by their xname keeping items that are not xnames last
+	var (
+		attribute = p.KwArgs["attribute"].String()
+		items     = make([]any, 0, in.Len())
+		names     = make([]string, 0, in.Len())
+	)
+	in.Iterate(func(idx, count int, key, value *exec.Value) bool {
+		name := key
+		if attribute != "" {
+			name, _ = key.Get(attribute)
+		}
+		items = append(items, key.Interface())
+		names = append(names, name.String())
+		return true
+	}, func() {})
+	return exec.AsValue(sortByXName(items, names))
+}
+
+// Returns the component type of the xname.
+func xnameType(s string) (string, error) {
+	x, err := xname.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return string(x.Type), nil
+}
+
+// Returns the xname one level up from the xname.
+func xnameParent(s string) (string, error) {
+	x, err := xname.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	parent, ok := x.Parent()
+	if !ok {
+		return "", fmt.Errorf("'%s' has no parent", x)
+	}
+	return parent.String(), nil
+}
+
+// Returns the xname of the component with the type that contains the xname.
+func xnameAncestor(s string, t xname.Type) (string, error) {
+	x, err := xname.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	ancestor, ok := x.Ancestor(t)
+	if !ok {
+		return "", fmt.Errorf("'%s' is not in a %s", x, t)
+	}
+	return ancestor.String(), nil
+}
+
+// Returns the items sorted by the xname with the same index in names. Items
+// that are not xnames come after the others in lexical order of their names.
+func sortByXName(items []any, names []string) []any {
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return xname.Less(names[order[i]], names[order[j]])
+	})
+	sorted := make([]any, len(order))
+	for i, index := range order {
+		sorted[i] = items[index]
+	}
+	return sorted
+}
@@ -8,7 +8,6 @@ import (
 	"plugin"
 
 	configurator "github.com/OpenCHAMI/configurator/pkg"
-	"github.com/OpenCHAMI/configurator/pkg/client"
 	"github.com/OpenCHAMI/configurator/pkg/config"
 	"github.com/OpenCHAMI/configurator/pkg/util"
 	"github.com/rs/zerolog/log"
@@ -27,15 +26,25 @@ type (
 		GetDescription() string
 		Generate(config *config.Config, params Params) (FileMap, error)
 	}
+
+	// Optional interface for generators to list the variables they pass to
+	// templates. These are checked against the variables that templates use
+	// with the "lint" command.
+	VariableLister interface {
+		GetVariables() []string
+	}
 )
 
+// Variables passed to templates by every built-in generator.
+var pluginVariables = []string{"plugin_name", "plugin_version", "plugin_description"}
+
 var DefaultGenerators = createDefaultGenerators()
 
 func createDefaultGenerators() map[string]Generator {
 	var (
 		generatorMap = map[string]Generator{}
 		generators   = []Generator{
-			&Conman{}, &DHCPd{}, &DNSMasq{}, &Warewulf{}, &Example{}, &CoreDhcp{},
+			&Conman{}, &DHCPd{}, &DNSMasq{}, &Warewulf{}, &Example{}, &CoreDhcp{}, &BootParams{},
 		}
 	)
 	for _, g := range generators {
@@ -182,9 +191,59 @@ func Generate(config *config.Config, plugin string, params Params) (FileMap, err
 		}
 	}
 
+	params.Templates = params.TemplatesWithVars()
 	return gen.Generate(config, params)
 }
 
+// Returns the generator used by the target. Built-in generators are used
+// first before loading the plugin set for the target or the plugin with the
+// same name as the target if one is not set.
+func FindGenerator(config *config.Config, target string) (Generator, error) {
+	// check if generator is built-in first before loading
+	generator, ok := DefaultGenerators[target]
+	if ok {
+		return generator, nil
+	}
+
+	// if no plugin supplied in config target, then using the target supplied
+	pluginPath := config.Targets[target].Plugin
+	if pluginPath == "" {
+		pluginPath = target
+	}
+
+	// only load the plugin needed for this target if we don't find default
+	log.Warn().Str("target", target).Msg("could not find target in default generators")
+	generator, err := LoadPlugin(pluginPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plugin: %v", err)
+	}
+	return generator, nil
+}
+
+// Loads the templates for the target from the config with the search path,
+// outputs, and strict mode set for the target.
+func loadTargetTemplates(config *config.Config, target string) (map[string]Template, error) {
+	var (
+		targetInfo = config.Targets[target]
+		templates  = map[string]Template{}
+	)
+	for _, templatePath := range targetInfo.TemplatePaths {
+		template := Template{
+			SearchPath: config.TemplateSearchPath(target),
+			Strict:     config.Strict,
+			Engine:     Engine(targetInfo.Engine),
+		}
+		if err := template.LoadFromFile(templatePath); err != nil {
+			return nil, fmt.Errorf("failed to load template '%s': %w", templatePath, err)
+		}
+		if output, ok := targetInfo.Outputs[templatePath]; ok {
+			template.Output = &output
+		}
+		templates[templatePath] = template
+	}
+	return templates, nil
+}
+
 // Main function to generate a collection of files as a map with the path as the key and
 // the contents of the file as the value. This function currently expects a list of plugin
 // paths to load all plugins within a directory. Then, each plugin's generator.GenerateWithTarget()
@@ -193,16 +252,17 @@ func Generate(config *config.Config, plugin string, params Params) (FileMap, err
 // This function is the corresponding implementation for the "generate" CLI subcommand.
 // It is also call when running the configurator as a service with the "/generate" route.
 //
+// Additional options can be supplied to modify the params passed to the
+// generator such as sharing a client.Cache between targets.
+//
 // TODO: Separate loading plugins so we can load them once when running as a service.
-func GenerateWithTarget(config *config.Config, target string) (FileMap, error) {
+func GenerateWithTarget(config *config.Config, target string, opts ...Option) (FileMap, error) {
 	// load generator plugins to generate configs or to print
 	var (
-		opts       []client.Option
 		targetInfo configurator.Target
 		generator  Generator
 		params     Params
 		err        error
-		ok         bool
 	)
 
 	// check if a target is supplied
@@ -211,25 +271,14 @@ func GenerateWithTarget(config *config.Config, target string) (FileMap, error) {
 	}
 
 	// load target information from config
-	targetInfo, ok = config.Targets[target]
+	targetInfo, ok := config.Targets[target]
 	if !ok {
 		log.Warn().Str("target", target).Msg("target not found in config")
 	}
 
-	// if no plugin supplied in config target, then using the target supplied
-	if targetInfo.Plugin == "" {
-		targetInfo.Plugin = target
-	}
-
-	// check if generator is built-in first before loading
-	generator, ok = DefaultGenerators[target]
-	if !ok {
-		// only load the plugin needed for this target if we don't find default
-		log.Warn().Str("target", target).Msg("could not find target in default generators")
-		generator, err = LoadPlugin(targetInfo.Plugin)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load plugin: %v", err)
-		}
+	generator, err = FindGenerator(config, target)
+	if err != nil {
+		return nil, err
 	}
 
 	// check if there's at least one template available
@@ -238,20 +287,15 @@ func GenerateWithTarget(config *config.Config, target string) (FileMap, error) {
 	}
 
 	// prepare params to pass into generator
-	params.Templates = map[string]Template{}
-	for _, templatePath := range targetInfo.TemplatePaths {
-		template := Template{}
-		template.LoadFromFile(templatePath)
-		params.Templates[templatePath] = template
+	params.Templates, err = loadTargetTemplates(config, target)
+	if err != nil {
+		return nil, err
 	}
 
-	// set the client options
-	if config.AccessToken != "" {
-		params.ClientOpts = append(opts, client.WithAccessToken(config.AccessToken))
-	}
-	if config.CertPath != "" {
-		params.ClientOpts = append(opts, client.WithCertPoolFile(config.CertPath))
-	}
+	// set the client options and variables from the config
+	params.ClientOpts = config.SmdClientOptions()
+	params.BssClientOpts = config.BssClientOptions()
+	params.Vars = config.TemplateVars(target)
 
 	// load files that are not to be copied
 	params.Files, err = LoadFiles(targetInfo.FilePaths...)
@@ -259,6 +303,37 @@ func GenerateWithTarget(config *config.Config, target string) (FileMap, error) {
 		return nil, fmt.Errorf("failed to load files to copy: %v", err)
 	}
 
-	// run the generator plugin from target passed
-	return generator.Generate(config, params)
+	// apply any additional options last
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	// run the generator plugin from target passed with the variables set on
+	// the templates
+	params.Templates = params.TemplatesWithVars()
+	outputs, err := generator.Generate(config, params)
+	if err != nil || len(targetInfo.Outputs) == 0 {
+		return outputs, err
+	}
+	return RelativeOutputs(outputs, params)
+}
+
+// Returns the outputs keyed by a path relative to the output directory. The
+// outputs of templates without an output path set and of files are keyed by
+// their base name while outputs written to an output path keep that path.
+// Returns an error if more than one output has the same path.
+func RelativeOutputs(outputs FileMap, params Params) (FileMap, error) {
+	relative := make(FileMap, len(outputs))
+	for path, contents := range outputs {
+		template, isTemplate := params.Templates[path]
+		_, isFile := params.Files[path]
+		if (isTemplate && template.Output == nil) || isFile {
+			path = filepath.Base(path)
+		}
+		if _, ok := relative[path]; ok {
+			return nil, fmt.Errorf("more than one output writes to output path '%s'", path)
+		}
+		relative[path] = contents
+	}
+	return relative, nil
 }
@@ -0,0 +1,187 @@
+// Package generatortest provides a golden file test harness for generators.
+// Each generator is ran against a fixture inventory served by a fake SMD
+// service and the resulting FileMap is compared with the golden files that
+// are checked in with the tests. Run the tests with the "-update" flag to
+// regenerate the golden files after an intentional change in output.
+//
+// The harness works with built-in generators and external plugins alike:
+//
+//	func TestMyPlugin(t *testing.T) {
+//		gen, err := generator.LoadPlugin("lib/myplugin.so")
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		generatortest.Run(t, "testdata/golden", generatortest.Case{
+//			Name:      "myplugin",
+//			Generator: gen,
+//			Templates: generatortest.LoadTemplates(t, "templates/myplugin.j2"),
+//			Fixtures:  smdtest.DefaultFixtures(),
+//		})
+//	}
+package generatortest
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/configurator/pkg/client"
+	"github.com/OpenCHAMI/configurator/pkg/client/smdtest"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+)
+
+var update = flag.Bool("update", false, "update the golden files instead of comparing them")
+
+const (
+	// Golden file that stores the error returned by a generator.
+	errorFile = "error.golden"
+
+	// Replaces the generator version in the output since it changes with
+	// every commit.
+	versionPlaceholder = "<VERSION>"
+)
+
+// A single golden file test case. The golden files are stored in a directory
+// using the case name with a file for each output in the FileMap.
+type Case struct {
+	Name      string
+	Generator generator.Generator
+	Templates map[string]generator.Template
+	Files     map[string][]byte
+	Fixtures  smdtest.Fixtures
+
+	// Variables passed to the templates like the 'vars' set in the config
+	Vars map[string]any
+}
+
+// Runs each case as a subtest and compares the output with the golden files
+// found in the directory.
+func Run(t *testing.T, dir string, cases ...Case) {
+	t.Helper()
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			var (
+				caseDir = filepath.Join(dir, c.Name)
+				outputs = Generate(t, c)
+			)
+			if *update {
+				writeGoldenFiles(t, caseDir, outputs)
+				return
+			}
+			compareGoldenFiles(t, caseDir, outputs)
+		})
+	}
+}
+
+// Runs the generator in the case against a fake SMD service serving the
+// fixtures and returns the normalized outputs keyed by golden file name. If
+// the generator returns an error, the error message is returned instead.
+func Generate(t *testing.T, c Case) map[string][]byte {
+	t.Helper()
+	var (
+		conf    = config.New()
+		s       = smdtest.NewServer(c.Fixtures)
+		opts    = []client.Option{client.WithHost(s.URL), client.WithRetries(0, time.Millisecond)}
+		outputs = map[string][]byte{}
+	)
+	defer s.Close()
+
+	// set the variables on the templates like generator.Generate()
+	params := generator.Params{
+		Templates:     c.Templates,
+		Files:         c.Files,
+		ClientOpts:    opts,
+		BssClientOpts: opts,
+		Vars:          c.Vars,
+	}
+	params.Templates = params.TemplatesWithVars()
+	fileMap, err := c.Generator.Generate(&conf, params)
+	if err != nil {
+		outputs[errorFile] = []byte(err.Error() + "\n")
+		return outputs
+	}
+	version := c.Generator.GetVersion()
+	for path, contents := range fileMap {
+		if version != "" {
+			contents = bytes.ReplaceAll(contents, []byte(version), []byte(versionPlaceholder))
+		}
+		outputs[goldenName(path)] = contents
+	}
+	return outputs
+}
+
+// Loads the templates from the paths and keys them by their base name so
+// that the golden file names do not depend on where the tests are ran.
+func LoadTemplates(t *testing.T, paths ...string) map[string]generator.Template {
+	t.Helper()
+	templates := map[string]generator.Template{}
+	for _, path := range paths {
+		template := generator.Template{}
+		if err := template.LoadFromFile(path); err != nil {
+			t.Fatalf("failed to load template: %v", err)
+		}
+		templates[filepath.Base(path)] = template
+	}
+	return templates
+}
+
+func goldenName(path string) string {
+	name := strings.Trim(filepath.ToSlash(path), "/")
+	return strings.ReplaceAll(name, "/", "_") + ".golden"
+}
+
+func writeGoldenFiles(t *testing.T, dir string, outputs map[string][]byte) {
+	t.Helper()
+
+	// remove the old golden files so that stale outputs don't stick around
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("failed to remove golden files: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to make golden file directory: %v", err)
+	}
+	for name, contents := range outputs {
+		if err := os.WriteFile(filepath.Join(dir, name), contents, 0o644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+	}
+}
+
+func compareGoldenFiles(t *testing.T, dir string, outputs map[string][]byte) {
+	t.Helper()
+
+	// make sure that every golden file has a matching output
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read golden files (run with -update to create them): %v", err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if _, ok := outputs[entry.Name()]; !ok {
+			t.Errorf("missing output for golden file '%s'", entry.Name())
+		}
+		names = append(names, entry.Name())
+	}
+
+	// ...and that every output matches its golden file
+	for name, contents := range outputs {
+		if !slices.Contains(names, name) {
+			t.Errorf("missing golden file for output '%s' (run with -update to create it)", name)
+			continue
+		}
+		expected, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to read golden file: %v", err)
+		}
+		if !bytes.Equal(expected, contents) {
+			t.Errorf("output does not match golden file '%s'...\nexpected:\n%s\noutput:\n%s", name, string(expected), string(contents))
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	texttemplate "text/template"
+	"text/template/parse"
+
+	"github.com/OpenCHAMI/configurator/pkg/xname"
+	sprig "github.com/go-task/slim-sprig"
+)
+
+// Functions available in every Go template with the Sprig functions and the
+// same functions as the filters in DefaultFilters. Values piped into a
+// function are passed as the last argument (e.g. {{ .subnet | ip_nth 10 }}).
+var goTemplateFuncs = createGoTemplateFuncs()
+
+func createGoTemplateFuncs() texttemplate.FuncMap {
+	funcs := sprig.TxtFuncMap()
+
+	// templates can be sent to the server so don't let them read its environment
+	delete(funcs, "env")
+	delete(funcs, "expandenv")
+
+	maps.Copy(funcs, texttemplate.FuncMap{
+		"ip_network":      stringFunc(ipNetwork),
+		"ip_netmask":      stringFunc(ipNetmask),
+		"ip_prefix":       func(cidr any) (int, error) { return ipPrefix(fmt.Sprint(cidr)) },
+		"ip_reverse":      stringFunc(reverseName),
+		"ip_nth":          func(n int, cidr any) (string, error) { return nthAddr(fmt.Sprint(cidr), n) },
+		"mac_format":      goMACFormat,
+		"mac_to_pxelinux": stringFunc(macToPxelinux),
+		"xname_type":      stringFunc(xnameType),
+		"xname_parent":    stringFunc(xnameParent),
+		"xname_cabinet":   xnameAncestorFunc(xname.Cabinet),
+		"xname_chassis":   xnameAncestorFunc(xname.Chassis),
+		"xname_slot":      xnameAncestorFunc(xname.Slot),
+		"xname_bmc":       xnameAncestorFunc(xname.BMC),
+		"xname_sort":      goXNameSort,
+	})
+	return funcs
+}
+
+// Returns a function that can be called with any value in a template by
+// formatting it as a string first like the Jinja filters do.
+func stringFunc(f func(string) (string, error)) func(any) (string, error) {
+	return func(value any) (string, error) {
+		return f(fmt.Sprint(value))
+	}
+}
+
+func xnameAncestorFunc(t xname.Type) func(any) (string, error) {
+	return stringFunc(func(s string) (string, error) {
+		return xnameAncestor(s, t)
+	})
+}
+
+// Formats a MAC with an optional separator before it so that the MAC can be
+// piped in (e.g. {{ .mac | mac_format "-" }}).
+func goMACFormat(args ...any) (string, error) {
+	separator := ":"
+	switch len(args) {
+	case 1:
+	case 2:
+		separator = fmt.Sprint(args[0])
+	default:
+		return "", fmt.Errorf("expected a MAC and an optional separator")
+	}
+	mac, err := parseMAC(fmt.Sprint(args[len(args)-1]))
+	if err != nil {
+		return "", err
+	}
+	return formatMAC(mac, separator), nil
+}
+
+// Sorts a list of xnames or a list of items by an attribute with an optional
+// attribute name before the list (e.g. {{ .components | xname_sort "ID" }}).
+func goXNameSort(args ...any) ([]any, error) {
+	var attribute string
+	switch len(args) {
+	case 1:
+	case 2:
+		attribute = fmt.Sprint(args[0])
+	default:
+		return nil, fmt.Errorf("expected a list and an optional attribute")
+	}
+	list := reflect.ValueOf(args[len(args)-1])
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list")
+	}
+
+	var (
+		items = make([]any, 0, list.Len())
+		names = make([]string, 0, list.Len())
+	)
+	for i := 0; i < list.Len(); i++ {
+		item := list.Index(i).Interface()
+		items = append(items, item)
+		if attribute == "" {
+			names = append(names, fmt.Sprint(item))
+		} else {
+			names = append(names, attributeString(item, attribute))
+		}
+	}
+	return sortByXName(items, names), nil
+}
+
+// Returns the field of a struct or the key of a map with the name as a
+// string or an empty string if the item doesn't have it.
+func attributeString(item any, name string) string {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return ""
+		}
+		if value := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())); value.IsValid() {
+			return fmt.Sprint(value.Interface())
+		}
+	case reflect.Struct:
+		if value := v.FieldByName(name); value.IsValid() && value.CanInterface() {
+			return fmt.Sprint(value.Interface())
+		}
+	}
+	return ""
+}
+
+func renderGoTemplate(template Template, mappings Mappings) ([]byte, error) {
+	t, err := parseGoTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	b := bytes.Buffer{}
+	if err = t.Execute(&b, mappings); err != nil {
+		return nil, fmt.Errorf("failed to execute: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// Parses the template with Go's text/template and the functions in
+// goTemplateFuncs. Templates used with "template" that are not defined are
+// found using the search path the same way as Jinja includes. Strict
+// templates fail to render missing keys.
+func parseGoTemplate(template Template) (*texttemplate.Template, error) {
+	name := "root"
+	if template.Path != "" {
+		name = filepath.Base(template.Path)
+	}
+	t := newGoTemplate(name, template.Strict)
+	if _, err := t.Parse(string(template.Contents)); err != nil {
+		return nil, fmt.Errorf("failed to read template from file: %w", err)
+	}
+	if err := addGoTemplates(t, template); err != nil {
+		return nil, fmt.Errorf("failed to read template from file: %w", err)
+	}
+	return t, nil
+}
+
+func newGoTemplate(name string, strict bool) *texttemplate.Template {
+	t := texttemplate.New(name).Funcs(goTemplateFuncs)
+	if strict {
+		t.Option("missingkey=error")
+	}
+	return t
+}
+
+// Adds the templates that are used by the templates in t but not defined to
+// t until every template used is defined.
+func addGoTemplates(t *texttemplate.Template, root Template) error {
+	loader := newSearchPathLoader(root.Path, root.SearchPath)
+	for added := true; added; {
+		added = false
+		for _, defined := range t.Templates() {
+			if defined.Tree == nil {
+				continue
+			}
+			for _, name := range goTemplateNames(defined.Tree.Root, nil) {
+				if t.Lookup(name) != nil {
+					continue
+				}
+				path, err := loader.Resolve(name)
+				if err != nil {
+					return fmt.Errorf("failed to find template '%s': %w", name, err)
+				}
+				contents, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("failed to read template '%s': %w", name, err)
+				}
+				if _, err := t.New(name).Parse(string(contents)); err != nil {
+					return err
+				}
+				added = true
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the names of the templates used with "template" in the node.
+func goTemplateNames(node parse.Node, names []string) []string {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return names
+		}
+		for _, child := range n.Nodes {
+			names = goTemplateNames(child, names)
+		}
+	case *parse.IfNode:
+		names = goTemplateNames(n.List, goTemplateNames(n.ElseList, names))
+	case *parse.RangeNode:
+		names = goTemplateNames(n.List, goTemplateNames(n.ElseList, names))
+	case *parse.WithNode:
+		names = goTemplateNames(n.List, goTemplateNames(n.ElseList, names))
+	case *parse.TemplateNode:
+		names = append(names, n.Name)
+	}
+	return names
+}
@@ -0,0 +1,190 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/OpenCHAMI/configurator/pkg/config"
+)
+
+// The targets to run resolved from the targets that each target runs after
+// itself (set with "targets" in the config). Each target is only included
+// once even if more than one target runs it.
+type TargetGraph struct {
+	// Every target in the order that they can be ran in. A target always
+	// comes before the targets that it runs.
+	Targets []string
+
+	// The targets ran after each target.
+	Edges map[string][]string
+}
+
+// Resolves the targets and every target that they run into a graph. An error
+// is returned if the targets run each other in a cycle (e.g. A -> B -> A). A
+// target that runs itself is ignored.
+func ResolveTargets(config *config.Config, targets ...string) (*TargetGraph, error) {
+	var (
+		graph = &TargetGraph{Edges: map[string][]string{}}
+		order = map[string]int{}
+		state = map[string]int{} // 0: unvisited, 1: visiting, 2: visited
+		path  = []string{}
+		visit func(target string) error
+	)
+
+	// walk the targets depth-first to find cycles and the order to run in
+	visit = func(target string) error {
+		switch state[target] {
+		case 1:
+			// find where the cycle starts to show it in the error
+			start := 0
+			for i, t := range path {
+				if t == target {
+					start = i
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), target)
+			return fmt.Errorf("found cycle in targets: %s", strings.Join(cycle, " -> "))
+		case 2:
+			return nil
+		}
+		state[target] = 1
+		path = append(path, target)
+		order[target] = len(order)
+
+		edges := []string{}
+		for _, next := range config.Targets[target].RunTargets {
+			if next == target || slices.Contains(edges, next) {
+				continue
+			}
+			edges = append(edges, next)
+			if err := visit(next); err != nil {
+				return err
+			}
+		}
+		graph.Edges[target] = edges
+
+		path = path[:len(path)-1]
+		state[target] = 2
+		return nil
+	}
+	for _, target := range targets {
+		if err := visit(target); err != nil {
+			return nil, err
+		}
+	}
+
+	// order the targets so that each target comes after every target that
+	// runs it, using the order they were found in to break ties
+	incoming := map[string]int{}
+	for _, edges := range graph.Edges {
+		for _, next := range edges {
+			incoming[next]++
+		}
+	}
+	ready := []string{}
+	for target := range graph.Edges {
+		if incoming[target] == 0 {
+			ready = append(ready, target)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return order[ready[i]] < order[ready[j]] })
+		target := ready[0]
+		ready = ready[1:]
+		graph.Targets = append(graph.Targets, target)
+		for _, next := range graph.Edges[target] {
+			incoming[next]--
+			if incoming[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+	return graph, nil
+}
+
+// Returns the graph in the DOT format used by Graphviz.
+func (g *TargetGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph targets {\n")
+	for _, target := range g.Targets {
+		fmt.Fprintf(&b, "\t%q;\n", target)
+	}
+	for _, target := range g.Targets {
+		for _, next := range g.Edges[target] {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", target, next)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Calls run for every target in the graph. Each target is ran after all of
+// the targets that run it finish so that independent targets are ran in
+// parallel. Targets are skipped if a target that runs them failed. Returns
+// the first error from run.
+func (g *TargetGraph) Run(ctx context.Context, run func(ctx context.Context, target string) error) error {
+	var (
+		ctxRun, cancel = context.WithCancel(ctx)
+		done           = make(map[string]chan struct{}, len(g.Targets))
+		failed         = make(map[string]bool, len(g.Targets))
+		parents        = map[string][]string{}
+		wg             sync.WaitGroup
+		mu             sync.Mutex
+		firstErr       error
+	)
+	defer cancel()
+
+	for _, target := range g.Targets {
+		done[target] = make(chan struct{})
+		for _, next := range g.Edges[target] {
+			parents[next] = append(parents[next], target)
+		}
+	}
+
+	for _, target := range g.Targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			defer close(done[target])
+
+			// wait for every target that runs this target to finish first
+			for _, parent := range parents[target] {
+				<-done[parent]
+				mu.Lock()
+				skip := failed[parent]
+				mu.Unlock()
+				if skip {
+					mu.Lock()
+					failed[target] = true
+					mu.Unlock()
+					return
+				}
+			}
+			if ctxRun.Err() != nil {
+				mu.Lock()
+				failed[target] = true
+				mu.Unlock()
+				return
+			}
+
+			if err := run(ctxRun, target); err != nil {
+				mu.Lock()
+				failed[target] = true
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				cancel()
+			}
+		}(target)
+	}
+	wg.Wait()
+	if firstErr == nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
@@ -0,0 +1,152 @@
+package generator
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/client"
+)
+
+// Inventory fetched from SMD that the built-in generators pass to
+// templates. Only the inventory that a generator requires or that its
+// templates use is fetched, so the rest is left empty.
+type SmdInventory struct {
+	Components       []configurator.Component
+	Interfaces       []configurator.EthernetInterface
+	RedfishEndpoints []configurator.RedfishEndpoint
+
+	// hardware details that some configs need, such as the CPU and memory
+	// counts for Slurm or the Redfish URLs for power tools
+	Hardware           []configurator.HardwareInventory
+	ComponentEndpoints []configurator.ComponentEndpoint
+	ServiceEndpoints   []configurator.ServiceEndpoint
+
+	// names of the template variables that were fetched
+	fetched map[string]bool
+}
+
+// Names of the template variables set by SmdInventory.Mappings().
+var smdVariables = []string{"components", "interfaces", "redfish_endpoints", "smd"}
+
+// Fetches the inventory named by the required variables and the inventory
+// that the params' templates use from SMD using the client. Fetching only
+// what is used keeps generators from failing when an endpoint they don't
+// use is unavailable and from tracking inventory that never changes their
+// output with client.Cache.Track().
+func FetchSmdInventory(smdClient *client.SmdClient, params Params, required ...string) (*SmdInventory, error) {
+	var (
+		ctx       = params.GetContext()
+		used      = templatesVariables(params.Templates)
+		inventory = &SmdInventory{fetched: map[string]bool{}}
+		err       error
+	)
+	for _, name := range smdVariables {
+		inventory.fetched[name] = used[name] || slices.Contains(required, name)
+	}
+
+	if inventory.fetched["components"] {
+		inventory.Components, err = smdClient.FetchComponents(ctx, params.Verbose)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch components: %w", err)
+		}
+	}
+	if inventory.fetched["interfaces"] {
+		inventory.Interfaces, err = smdClient.FetchEthernetInterfaces(ctx, params.Verbose)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch ethernet interfaces: %w", err)
+		}
+	}
+	if inventory.fetched["redfish_endpoints"] {
+		inventory.RedfishEndpoints, err = smdClient.FetchRedfishEndpoints(ctx, params.Verbose)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch redfish endpoints: %w", err)
+		}
+	}
+	if inventory.fetched["smd"] {
+		inventory.Hardware, err = smdClient.FetchHardwareInventory(ctx, params.Verbose)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch hardware inventory: %w", err)
+		}
+		inventory.ComponentEndpoints, err = smdClient.FetchComponentEndpoints(ctx, params.Verbose)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch component endpoints: %w", err)
+		}
+		inventory.ServiceEndpoints, err = smdClient.FetchServiceEndpoints(ctx, params.Verbose)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch service endpoints: %w", err)
+		}
+	}
+	return inventory, nil
+}
+
+// Returns the fetched inventory as template variables so that templates can
+// format each item themselves instead of using the preformatted strings. The
+// hardware details are set under the "smd" namespace. The inventory can be
+// used in templates like so:
+//
+//	{% for c in components %}{{ c.ID }} {{ c.Role }}{% endfor %}
+//	{% for iface in interfaces %}dhcp-host={{ iface.MacAddress }},{{ iface.ComponentId }}{% endfor %}
+//	{% for ep in redfish_endpoints %}{{ ep.Name }} {{ ep.FQDN }}{% endfor %}
+//	{% for hw in smd.hardware %}{{ hw.NodeLocationInfo.ProcessorSummary.Count }}{% endfor %}
+//	{% for ep in smd.component_endpoints %}{{ ep.RedfishURL }}{% endfor %}
+//	{% for ep in smd.service_endpoints %}{{ ep.RedfishURL }}{% endfor %}
+//
+// The BMC passwords of the Redfish endpoints are never passed to templates.
+func (inventory *SmdInventory) Mappings() Mappings {
+	var (
+		mappings = Mappings{}
+		values   = map[string]any{
+			"components":        inventory.Components,
+			"interfaces":        inventory.Interfaces,
+			"redfish_endpoints": withoutPasswords(inventory.RedfishEndpoints),
+			"smd": map[string]any{
+				"hardware":            inventory.Hardware,
+				"component_endpoints": inventory.ComponentEndpoints,
+				"service_endpoints":   inventory.ServiceEndpoints,
+			},
+		}
+	)
+	for name, value := range values {
+		if inventory.fetched[name] {
+			mappings[name] = value
+		}
+	}
+	return mappings
+}
+
+// Returns a copy of the Redfish endpoints with their passwords removed.
+func withoutPasswords(eps []configurator.RedfishEndpoint) []configurator.RedfishEndpoint {
+	if eps == nil {
+		return nil
+	}
+	redacted := make([]configurator.RedfishEndpoint, len(eps))
+	for i, ep := range eps {
+		ep.Password = ""
+		redacted[i] = ep
+	}
+	return redacted
+}
+
+// Returns the names of the variables used by the templates and their output
+// paths including the lists they are rendered for. Templates that can't be
+// parsed are skipped since the error is returned when they are rendered.
+func templatesVariables(templates map[string]Template) map[string]bool {
+	used := map[string]bool{}
+	for _, template := range templates {
+		names, _ := TemplateVariables(template)
+		if template.Output != nil {
+			paths, _ := TemplateVariables(Template{Contents: []byte(template.Output.Path)})
+			names = append(names, paths...)
+			if template.Output.ForEach != "" {
+				name, _, _ := strings.Cut(template.Output.ForEach, ".")
+				names = append(names, name)
+			}
+		}
+		for _, name := range names {
+			used[name] = true
+		}
+	}
+	return used
+}
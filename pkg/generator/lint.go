@@ -0,0 +1,368 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"text/template/parse"
+
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+// Names used in templates that are part of the syntax or are always defined
+// so they are never reported as variables.
+var templateKeywords = map[string]bool{
+	"if": true, "else": true, "elif": true, "true": true, "false": true,
+	"none": true, "True": true, "False": true, "None": true,
+	"recursive": true, "with": true, "without": true, "context": true,
+	"ignore": true, "missing": true, "as": true, "import": true,
+	"loop": true, "caller": true, "self": true, "super": true,
+	"varargs": true, "kwargs": true,
+}
+
+// Result of checking a single template with LintTarget.
+type LintResult struct {
+	Path string
+
+	// Variables the template and the templates it includes use
+	Uses []string
+
+	// Variables the template uses that the generator doesn't provide. These
+	// may be fields of each item instead when ForEach is set.
+	Undefined []string
+
+	// List the template is rendered once for each item in
+	ForEach string
+
+	// Syntax error found when parsing the template
+	Err error
+}
+
+// Checks each of the target's templates for syntax errors and the variables
+// they use. Returns the variables the target's generator and the config's
+// 'vars' provide or nil if the generator doesn't list them with
+// VariableLister, in which case no variables are reported as undefined.
+func LintTarget(config *config.Config, target string) ([]string, []LintResult, error) {
+	if target == "" {
+		return nil, nil, fmt.Errorf("must specify a target")
+	}
+	generator, err := FindGenerator(config, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	templates, err := loadTargetTemplates(config, target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// variables set in the config are provided along with the generator's
+	var provides []string
+	if lister, ok := generator.(VariableLister); ok {
+		provides = slices.Clone(lister.GetVariables())
+		for name := range config.TemplateVars(target) {
+			provides = append(provides, name)
+		}
+		sort.Strings(provides)
+		provides = slices.Compact(provides)
+	}
+
+	paths := make([]string, 0, len(templates))
+	for path := range templates {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	results := make([]LintResult, 0, len(paths))
+	for _, path := range paths {
+		var (
+			template = templates[path]
+			result   = LintResult{Path: path}
+		)
+		result.Uses, result.Err = TemplateVariables(template)
+		if result.Err != nil {
+			results = append(results, result)
+			continue
+		}
+		available := slices.Clone(provides)
+		if template.Output != nil && template.Output.ForEach != "" {
+			result.ForEach = template.Output.ForEach
+			available = append(available, "item")
+		}
+		if provides != nil {
+			for _, name := range result.Uses {
+				if !slices.Contains(available, name) {
+					result.Undefined = append(result.Undefined, name)
+				}
+			}
+		}
+		results = append(results, result)
+	}
+	return provides, results, nil
+}
+
+// Parses the template and returns the sorted names of the variables it uses
+// that are not set in the template itself. Templates included, extended, or
+// imported by name are checked too. Syntax errors are returned with the line
+// and column they were found at.
+//
+// The variables of Go templates are the keys of the mappings they use
+// either from "." at the top level or from "$" anywhere.
+func TemplateVariables(template Template) ([]string, error) {
+	switch template.Engine {
+	case "", EngineJinja:
+	case EngineGoTemplate:
+		return goTemplateVariables(template)
+	default:
+		return nil, fmt.Errorf("unknown template engine '%s'", template.Engine)
+	}
+
+	var (
+		used    = map[string]bool{}
+		defined = map[string]bool{}
+		visited = map[string]bool{}
+	)
+	if err := scanTemplate(template, used, defined, visited); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range used {
+		if defined[name] || templateKeywords[name] || gonja.DefaultContext.Has(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Adds the names used and set in the template to the maps and scans any
+// other templates it references that haven't been visited yet.
+func scanTemplate(template Template, used, defined, visited map[string]bool) error {
+	toks, err := lexTemplate(template.Contents)
+	if err != nil {
+		return err
+	}
+	if _, err := parseTemplate(template); err != nil {
+		return err
+	}
+
+	loader := newSearchPathLoader(template.Path, template.SearchPath)
+	for _, name := range scanNames(toks, used, defined) {
+		// missing templates are already reported when parsing
+		path, err := loader.Resolve(name)
+		if err != nil || visited[path] {
+			continue
+		}
+		visited[path] = true
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read template '%s': %w", name, err)
+		}
+		err = scanTemplate(Template{Contents: contents, Path: path, SearchPath: template.SearchPath}, used, defined, visited)
+		if err != nil {
+			return fmt.Errorf("failed to check template '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Returns the tokens in the template or the first error found by the lexer
+// with its line and column.
+func lexTemplate(contents []byte) ([]*tokens.Token, error) {
+	// the lexer never finishes with an unclosed tag
+	if err := checkTagsClosed(contents); err != nil {
+		return nil, err
+	}
+
+	var (
+		input  = string(contents)
+		stream = tokens.Lex(input, gonja.DefaultConfig)
+		toks   = []*tokens.Token{}
+	)
+	for ; !stream.End(); stream.Next() {
+		toks = append(toks, stream.Current())
+	}
+	if stream.IsError() {
+		tok := stream.Current()
+		line, col := tokens.ReadablePosition(tok.Pos, input)
+		return nil, fmt.Errorf("syntax error at line %d column %d: %s", line, col, tok.Val)
+	}
+	return toks, nil
+}
+
+// Adds the names of the variables used and set in the tags of the template
+// to the maps. Returns the names of the other templates that are included,
+// extended, or imported.
+//
+// Names that are attributes, filters, tests, keyword arguments, or the names
+// of blocks are skipped. Names set with "set", "for", "with", "macro", and
+// imports are added as defined.
+func scanNames(toks []*tokens.Token, used, defined map[string]bool) []string {
+	var (
+		templates []string
+		inTag     bool
+		keyword   string
+		binding   bool
+		depth     int
+	)
+	for i, tok := range toks {
+		switch tok.Type {
+		case tokens.VariableBegin, tokens.BlockBegin:
+			inTag, keyword, binding, depth = true, "", false, 0
+			continue
+		case tokens.VariableEnd, tokens.BlockEnd:
+			inTag = false
+			continue
+		case tokens.LeftParenthesis, tokens.LeftBracket, tokens.LeftBrace:
+			depth++
+			continue
+		case tokens.RightParenthesis, tokens.RightBracket, tokens.RightBrace:
+			depth--
+			continue
+		case tokens.Assign:
+			if keyword == "set" && depth == 0 {
+				binding = false
+			}
+			continue
+		case tokens.In:
+			if keyword == "for" {
+				binding = false
+			}
+			continue
+		case tokens.String:
+			switch keyword {
+			case "include", "extends", "import", "from":
+				templates = append(templates, tok.Val)
+			}
+			continue
+		case tokens.Name:
+		default:
+			continue
+		}
+		if !inTag {
+			continue
+		}
+
+		var (
+			prev = toks[i-1]
+			next *tokens.Token
+		)
+		if i+1 < len(toks) {
+			next = toks[i+1]
+		}
+		switch {
+		case prev.Type == tokens.BlockBegin:
+			// the first name in a block is the statement
+			keyword = tok.Val
+			binding = keyword == "for" || keyword == "set" || keyword == "import" || keyword == "from"
+		case prev.Type == tokens.Dot || prev.Type == tokens.Pipe:
+			// attributes and filters
+		case prev.Type == tokens.Is:
+			// tests
+		case prev.Type == tokens.Not && i >= 2 && toks[i-2].Type == tokens.Is:
+			// negated tests
+		case prev.Type == tokens.Name && prev.Val == keyword && (keyword == "block" || keyword == "filter"):
+			// names of blocks and filters applied to blocks
+		case keyword == "macro" && (prev.Val == keyword || (depth == 1 && (prev.Type == tokens.LeftParenthesis || prev.Type == tokens.Comma))):
+			// the macro and its parameters
+			defined[tok.Val] = true
+		case next != nil && next.Type == tokens.Assign && depth > 0:
+			// keyword arguments
+		case binding || (next != nil && next.Type == tokens.Assign):
+			defined[tok.Val] = true
+		default:
+			used[tok.Val] = true
+		}
+	}
+	return templates
+}
+
+// Returns the sorted names of the variables used by the Go template and the
+// templates it uses with "template" and ".".
+func goTemplateVariables(template Template) ([]string, error) {
+	t, err := parseGoTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		used    = map[string]bool{}
+		visited = map[string]bool{t.Name(): true}
+		scan    func(node parse.Node, top bool)
+	)
+	scan = func(node parse.Node, top bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				scan(child, top)
+			}
+		case *parse.ActionNode:
+			scan(n.Pipe, top)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				scan(cmd, top)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				scan(arg, top)
+			}
+		case *parse.ChainNode:
+			scan(n.Node, top)
+		case *parse.FieldNode:
+			if top {
+				used[n.Ident[0]] = true
+			}
+		case *parse.VariableNode:
+			if n.Ident[0] == "$" && len(n.Ident) > 1 {
+				used[n.Ident[1]] = true
+			}
+		case *parse.IfNode:
+			scan(n.Pipe, top)
+			scan(n.List, top)
+			scan(n.ElseList, top)
+		case *parse.RangeNode:
+			// "." is each item in the list
+			scan(n.Pipe, top)
+			scan(n.List, false)
+			scan(n.ElseList, top)
+		case *parse.WithNode:
+			// "." is the value of the pipeline
+			scan(n.Pipe, top)
+			scan(n.List, false)
+			scan(n.ElseList, top)
+		case *parse.TemplateNode:
+			scan(n.Pipe, top)
+			if !top || n.Pipe == nil || len(n.Pipe.Cmds) != 1 || len(n.Pipe.Cmds[0].Args) != 1 {
+				return
+			}
+			if _, ok := n.Pipe.Cmds[0].Args[0].(*parse.DotNode); !ok || visited[n.Name] {
+				return
+			}
+			visited[n.Name] = true
+			if tmpl := t.Lookup(n.Name); tmpl != nil && tmpl.Tree != nil {
+				scan(tmpl.Tree.Root, true)
+			}
+		}
+	}
+	if t.Tree != nil {
+		scan(t.Tree.Root, true)
+	}
+
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,82 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/nikolalohinski/gonja/v2/loaders"
+)
+
+// Loader used to find the templates used with "include", "extends", and
+// "import" in another template. Relative names are looked up next to the
+// template that uses them first and then in each directory of the search
+// path in order. Templates that only exist in memory use the working
+// directory last instead.
+type searchPathLoader struct {
+	dir        string
+	searchPath []string
+}
+
+// Returns a loader for the template at the path that looks up other templates
+// in the search path. The path can be empty for templates that only exist in
+// memory.
+func newSearchPathLoader(path string, searchPath []string) loaders.Loader {
+	loader := &searchPathLoader{searchPath: searchPath}
+	if path != "" {
+		loader.dir = filepath.Dir(path)
+	}
+	return loader
+}
+
+func (l *searchPathLoader) Read(name string) (io.Reader, error) {
+	path, err := l.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	return bytes.NewReader(b), nil
+}
+
+func (l *searchPathLoader) Resolve(name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return name, nil
+	}
+	dirs := append([]string{}, l.searchPath...)
+	if l.dir != "" {
+		dirs = append([]string{l.dir}, dirs...)
+	} else {
+		dirs = append(dirs, ".")
+	}
+	for _, dir := range dirs {
+		path, err := filepath.Abs(filepath.Join(dir, name))
+		if err != nil {
+			return "", fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		_, err = os.Stat(path)
+		if err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to stat template: %w", err)
+		}
+	}
+	return "", fmt.Errorf("template '%s' not found in search path %v", name, dirs)
+}
+
+func (l *searchPathLoader) Inherit(from string) (loaders.Loader, error) {
+	if from == "" {
+		return &searchPathLoader{dir: l.dir, searchPath: l.searchPath}, nil
+	}
+	path, err := l.Resolve(from)
+	if err != nil {
+		return nil, err
+	}
+	return newSearchPathLoader(path, l.searchPath), nil
+}
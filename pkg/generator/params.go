@@ -1,6 +1,9 @@
 package generator
 
 import (
+	"context"
+	"maps"
+
 	configurator "github.com/OpenCHAMI/configurator/pkg"
 	"github.com/OpenCHAMI/configurator/pkg/client"
 	"github.com/OpenCHAMI/configurator/pkg/config"
@@ -9,34 +12,102 @@ import (
 type (
 	// Params used by the generator
 	Params struct {
-		Templates  map[string]Template
-		Files      map[string][]byte
-		ClientOpts []client.Option
-		Verbose    bool
+		Context       context.Context
+		Templates     map[string]Template
+		Files         map[string][]byte
+		ClientOpts    []client.Option
+		BssClientOpts []client.Option
+		Verbose       bool
+
+		// Variables passed to templates that replace the ones set by the
+		// generator with the same name
+		Vars map[string]any
 	}
-	Option func(Params)
+	Option func(*Params)
 )
 
 func ToParams(opts ...Option) Params {
 	params := Params{}
 	for _, opt := range opts {
-		opt(params)
+		opt(&params)
 	}
 	return params
 }
 
+// Appends client options to the ones already set in the params.
 func WithClientOpts(opts ...client.Option) Option {
-	return func(p Params) {
-		p.ClientOpts = opts
+	return func(p *Params) {
+		p.ClientOpts = append(p.ClientOpts, opts...)
+	}
+}
+
+// Sets the cache shared by the SMD and BSS clients created by generators.
+func WithCache(cache *client.Cache) Option {
+	return func(p *Params) {
+		p.ClientOpts = append(p.ClientOpts, client.WithCache(cache))
+		p.BssClientOpts = append(p.BssClientOpts, client.WithCache(cache))
+	}
+}
+
+// Sets the context used by generators when making requests.
+func WithContext(ctx context.Context) Option {
+	return func(p *Params) {
+		p.Context = ctx
 	}
 }
 
 func WithTemplates(templates map[string]Template) Option {
-	return func(p Params) {
+	return func(p *Params) {
 		p.Templates = templates
 	}
 }
 
+// Sets variables passed to templates. Variables that are already set with
+// the same name are replaced.
+func WithVars(vars map[string]any) Option {
+	return func(p *Params) {
+		if p.Vars == nil {
+			p.Vars = make(map[string]any, len(vars))
+		}
+		maps.Copy(p.Vars, vars)
+	}
+}
+
+// Copies the variables set in the params over the mappings set by a generator
+// so that the config and CLI can override them. The variables are already set
+// on the templates passed to generators and override the mappings when the
+// templates are applied, so this is only needed to use the variables in the
+// mappings before then.
+func (p Params) MergeVars(mappings Mappings) Mappings {
+	maps.Copy(mappings, p.Vars)
+	return mappings
+}
+
+// Returns a copy of the templates in the params with the variables set on
+// each so that they override the mappings set by any generator when the
+// templates are applied. Generate() and GenerateWithTarget() do this before
+// calling a generator so that external plugins also use the variables.
+func (p Params) TemplatesWithVars() map[string]Template {
+	if len(p.Vars) == 0 {
+		return p.Templates
+	}
+	templates := make(map[string]Template, len(p.Templates))
+	for path, template := range p.Templates {
+		template.Vars = p.Vars
+		templates[path] = template
+	}
+	return templates
+}
+
+// Returns the context to use when making requests in generator.Generate()
+// plugin implementations. Defaults to context.Background() if not set.
+func (p Params) GetContext() context.Context {
+	if p.Context == nil {
+		return context.Background()
+	}
+	return p.Context
+}
+
 // Helper function to get the target in generator.Generate() plugin implementations.
 func GetTarget(config *config.Config, key string) configurator.Target {
 	return config.Targets[key]
@@ -21,6 +21,6 @@ func (g *Powerman) GetDescription() string {
 	return fmt.Sprintf("Configurator generator plugin for '%s'.", g.GetName())
 }
 
-func (g *Powerman) Generate(config *config.Config, opts ...Option) (FileMap, error) {
+func (g *Powerman) Generate(config *config.Config, params Params) (FileMap, error) {
 	return nil, fmt.Errorf("plugin does not implement generation function")
 }
@@ -2,17 +2,89 @@ package generator
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
 
+	configurator "github.com/OpenCHAMI/configurator/pkg"
 	"github.com/OpenCHAMI/configurator/pkg/util"
 	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/builtins"
 	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+	"github.com/nikolalohinski/gonja/v2/tokens"
 	"github.com/rs/zerolog/log"
 )
 
+// Environment used to render every template with the built-in gonja filters
+// and the filters in DefaultFilters.
+var environment = &exec.Environment{
+	Context: gonja.DefaultContext,
+	Filters: exec.NewFilterSet(map[string]exec.FilterFunction{}).
+		Update(builtins.Filters).
+		Update(filterSet(DefaultFilters)),
+	Tests:             builtins.Tests,
+	ControlStructures: builtins.ControlStructures,
+	Methods:           builtins.Methods,
+}
+
+// Start and end of the raw blocks that are not parsed as templates.
+var (
+	rawBlock    = regexp.MustCompile(`^\{%-?\s*raw\s*-?%\}`)
+	endRawBlock = regexp.MustCompile(`\{%-?\s*endraw\s*-?%\}`)
+)
+
+// Engine used to render a template.
+type Engine string
+
+const (
+	EngineJinja      Engine = "jinja"
+	EngineGoTemplate Engine = "gotmpl"
+)
+
+// Extensions of templates that are rendered with Go's text/template instead
+// of Jinja when the engine isn't set.
+var goTemplateExtensions = []string{".gotmpl", ".tmpl", ".tpl"}
+
+// Returns the engine for a template by the extension of its path. Templates
+// without one of the Go template extensions are Jinja.
+func EngineFromPath(path string) Engine {
+	if slices.Contains(goTemplateExtensions, filepath.Ext(path)) {
+		return EngineGoTemplate
+	}
+	return EngineJinja
+}
+
 type Template struct {
-	Contents []byte `json:"contents"`
+	Contents []byte               `json:"contents"`
+	Output   *configurator.Output `json:"output,omitempty"`
+
+	// Engine to render the template with detected by the extension of the
+	// path when loaded from a file if not set (defaults to Jinja)
+	Engine Engine `json:"engine,omitempty"`
+
+	// Path the template was loaded from used to find other templates next
+	// to it with "include", "extends", and "import"
+	Path string `json:"path,omitempty"`
+
+	// Directories to look for other templates in after the template's own
+	// directory
+	SearchPath []string `json:"-"`
+
+	// Return an error for undefined variables instead of rendering them as
+	// empty values
+	Strict bool `json:"-"`
+
+	// Variables from the config and CLI that override the mappings set by
+	// the generator
+	Vars map[string]any `json:"-"`
 }
 
 func (t *Template) LoadFromFile(path string) error {
@@ -31,6 +103,10 @@ func (t *Template) LoadFromFile(path string) error {
 		return fmt.Errorf("failed to read file: %v", err)
 	}
 	t.Contents = contents
+	t.Path = path
+	if t.Engine == "" {
+		t.Engine = EngineFromPath(path)
+	}
 	return nil
 }
 
@@ -44,31 +120,258 @@ func (t *Template) IsEmpty() bool {
 // there will be no output.
 //
 // The "FileList" returns a slice of byte arrays in the same order as the argument
-// list supplied, but with the Jinja templating applied.
+// list supplied, but with the templating applied. Each template is rendered
+// with its own engine using the same mappings.
+//
+// Templates with an output set are keyed by the rendered output path instead
+// of the template path. An error is returned if two templates would write to
+// the same output path.
 func ApplyTemplates(mappings Mappings, templates map[string]Template) (FileMap, error) {
+	outputs := FileMap{}
+
+	for path, template := range templates {
+		mappings := template.withVars(mappings)
+		if template.Output != nil {
+			rendered, err := applyTemplateOutput(mappings, template)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render output for template '%s': %w", path, err)
+			}
+			for outputPath, contents := range rendered {
+				if _, ok := outputs[outputPath]; ok {
+					return nil, fmt.Errorf("more than one template writes to output path '%s'", outputPath)
+				}
+				outputs[outputPath] = contents
+			}
+			continue
+		}
+
+		b, err := renderTemplate(template, mappings)
+		if err != nil {
+			return nil, err
+		}
+		outputs[path] = b
+	}
+
+	log.Debug().Any("templates", templates).Any("outputs", outputs).Any("mappings", mappings).Msg("apply templates")
+
+	return outputs, nil
+}
+
+// Returns a copy of the mappings with the template's variables set over them
+// or the mappings if the template has no variables.
+func (t Template) withVars(mappings Mappings) Mappings {
+	if len(t.Vars) == 0 {
+		return mappings
+	}
+	merged := make(Mappings, len(mappings)+len(t.Vars))
+	maps.Copy(merged, mappings)
+	maps.Copy(merged, t.Vars)
+	return merged
+}
+
+// Renders the template once for its output path or once for every item in
+// the list set with "foreach". Returns the outputs keyed by output path.
+func applyTemplateOutput(mappings Mappings, template Template) (FileMap, error) {
 	var (
-		data    = exec.NewContext(mappings)
 		outputs = FileMap{}
+		items   = []Mappings{mappings}
 	)
 
-	for path, template := range templates {
-		// load jinja template from file
-		t, err := gonja.FromBytes(template.Contents)
+	// add each item's fields to a copy of the mappings
+	if template.Output.ForEach != "" {
+		list, err := lookupList(mappings, template.Output.ForEach)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read template from file: %w", err)
+			return nil, err
+		}
+		items = make([]Mappings, 0, len(list))
+		for _, item := range list {
+			itemMappings := make(Mappings, len(mappings)+1)
+			for k, v := range mappings {
+				itemMappings[k] = v
+			}
+			for k, v := range itemFields(item) {
+				itemMappings[k] = v
+			}
+			itemMappings["item"] = item
+			items = append(items, itemMappings)
 		}
+	}
 
-		// execute/render jinja template
-		b := bytes.Buffer{}
-		if err = t.Execute(&b, data); err != nil {
-			return nil, fmt.Errorf("failed to execute: %w", err)
+	for _, itemMappings := range items {
+		// output paths are always Jinja like the rest of the config
+		b, err := renderTemplate(Template{Contents: []byte(template.Output.Path), Strict: template.Strict}, itemMappings)
+		if err != nil {
+			return nil, err
+		}
+		outputPath, err := cleanOutputPath(string(b))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := outputs[outputPath]; ok {
+			return nil, fmt.Errorf("more than one item writes to output path '%s'", outputPath)
+		}
+		outputs[outputPath], err = renderTemplate(template, itemMappings)
+		if err != nil {
+			return nil, err
 		}
-		outputs[path] = b.Bytes()
 	}
+	return outputs, nil
+}
 
-	log.Debug().Any("templates", templates).Any("outputs", outputs).Any("mappings", mappings).Msg("apply templates")
+// Renders the template with the mappings using the template's engine.
+func renderTemplate(template Template, mappings Mappings) ([]byte, error) {
+	switch template.Engine {
+	case "", EngineJinja:
+	case EngineGoTemplate:
+		return renderGoTemplate(template, mappings)
+	default:
+		return nil, fmt.Errorf("unknown template engine '%s'", template.Engine)
+	}
 
-	return outputs, nil
+	t, err := parseTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	// execute/render jinja template
+	b := bytes.Buffer{}
+	if err = t.Execute(&b, exec.NewContext(mappings)); err != nil {
+		return nil, fmt.Errorf("failed to execute: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// Parses the template contents the same way as gonja.FromBytes() but with
+// the filters added to the environment and other templates found using the
+// search path. Strict templates fail to render undefined variables.
+func parseTemplate(template Template) (*exec.Template, error) {
+	// gonja never returns when parsing a template with an unclosed tag
+	if err := checkTagsClosed(template.Contents); err != nil {
+		return nil, err
+	}
+
+	cfg := gonja.DefaultConfig
+	if template.Strict {
+		cfg = cfg.Inherit()
+		cfg.StrictUndefined = true
+	}
+	rootID := fmt.Sprintf("root-%x", sha256.Sum256(template.Contents))
+	loader := newSearchPathLoader(template.Path, template.SearchPath)
+	shiftedLoader, err := loaders.NewShiftedLoader(rootID, bytes.NewReader(template.Contents), loader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create template loader: %w", err)
+	}
+	t, err := exec.NewTemplate(rootID, cfg, shiftedLoader, environment)
+	if err != nil {
+		// gonja puts the whole template in the error so remove it
+		prefix := fmt.Sprintf("failed to parse template '%s': ", template.Contents)
+		return nil, fmt.Errorf("failed to read template from file: %s", strings.TrimPrefix(err.Error(), prefix))
+	}
+	return t, nil
+}
+
+// Returns an error with the line and column of the first variable or block
+// tag that is never closed. Tags inside of raw blocks are ignored.
+func checkTagsClosed(contents []byte) error {
+	var (
+		s      = string(contents)
+		offset = 0
+	)
+	for {
+		start := strings.IndexByte(s[offset:], '{')
+		if start < 0 {
+			return nil
+		}
+		start += offset
+		rest := s[start:]
+		switch {
+		case rawBlock.MatchString(rest):
+			// skip everything up to the end of the raw block
+			end := endRawBlock.FindStringIndex(rest)
+			if end == nil {
+				return nil
+			}
+			offset = start + end[1]
+		case strings.HasPrefix(rest, "{#"):
+			// unclosed comments are already reported by gonja
+			end := strings.Index(rest[2:], "#}")
+			if end < 0 {
+				return nil
+			}
+			offset = start + 2 + end + 2
+		case strings.HasPrefix(rest, "{{"), strings.HasPrefix(rest, "{%"):
+			closing := "}}"
+			if rest[1] == '%' {
+				closing = "%}"
+			}
+			end := strings.Index(rest[2:], closing)
+			if end < 0 {
+				line, col := tokens.ReadablePosition(start, s)
+				return fmt.Errorf("unclosed '%s' at line %d column %d", rest[:2], line, col)
+			}
+			offset = start + 2 + end + len(closing)
+		default:
+			offset = start + 1
+		}
+	}
+}
+
+// Returns the list in the mappings with the name. Nested mappings can be
+// accessed with dots (e.g. "smd.hardware").
+func lookupList(mappings Mappings, name string) ([]any, error) {
+	var value any = map[string]any(mappings)
+	for _, key := range strings.Split(name, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			if mm, isMappings := value.(Mappings); isMappings {
+				m, ok = mm, true
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("'%s' not found in mappings", name)
+		}
+		if value, ok = m[key]; !ok {
+			return nil, fmt.Errorf("'%s' not found in mappings", name)
+		}
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("'%s' is not a list", name)
+	}
+	list := make([]any, v.Len())
+	for i := range list {
+		list[i] = v.Index(i).Interface()
+	}
+	return list, nil
+}
+
+// Returns the fields of an item as mappings using the same names as when the
+// item is marshalled to JSON. Items that are not objects have no fields.
+func itemFields(item any) map[string]any {
+	fields := map[string]any{}
+	b, err := json.Marshal(item)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(b, &fields)
+	return fields
+}
+
+// Cleans a rendered output path so that it is always relative to the output
+// directory. Paths that would be written outside of it are not allowed.
+func cleanOutputPath(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	cleaned := filepath.Clean("/" + filepath.ToSlash(path))
+	cleaned = strings.TrimPrefix(cleaned, "/")
+	if path == "" || cleaned == "" || cleaned == "." {
+		return "", fmt.Errorf("output path '%s' is empty", path)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part == ".." {
+			return "", fmt.Errorf("output path '%s' must not contain '..'", path)
+		}
+	}
+	return cleaned, nil
 }
 
 // Wrapper function similiar to "ApplyTemplates" but takes file paths as arguments.
@@ -81,7 +384,11 @@ func ApplyTemplateFromFiles(mappings Mappings, paths ...string) (FileMap, error)
 
 	for _, path := range paths {
 		// load jinja template from file
-		t, err := gonja.FromFile(path)
+		loader, err := loaders.NewFileSystemLoader(filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create template loader: %w", err)
+		}
+		t, err := exec.NewTemplate(filepath.Base(path), gonja.DefaultConfig, loader, environment)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read template from file: %w", err)
 		}
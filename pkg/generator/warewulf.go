@@ -3,6 +3,7 @@ package generator
 import (
 	"fmt"
 	"maps"
+	"slices"
 	"strings"
 
 	"github.com/OpenCHAMI/configurator/pkg/client"
@@ -25,19 +26,30 @@ func (g *Warewulf) GetDescription() string {
 	return "Configurator generator plugin for 'warewulf' config files."
 }
 
+// Returns the variables passed to templates.
+func (g *Warewulf) GetVariables() []string {
+	return slices.Concat([]string{"node_entries"}, smdVariables)
+}
+
 func (g *Warewulf) Generate(config *config.Config, params Params) (FileMap, error) {
 	var (
-		smdClient   = client.NewSmdClient(params.ClientOpts...)
 		outputs     = make(FileMap, len(params.Templates))
 		nodeEntries = ""
 		paths       = []string{}
 	)
 
+	// create the client first so that invalid options are returned
+	smdClient, err := client.NewSmdClient(params.ClientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
 	// if we have a client, try making the request for the ethernet interfaces
-	eths, err := smdClient.FetchEthernetInterfaces(params.Verbose)
+	inventory, err := FetchSmdInventory(&smdClient, params, "interfaces", "redfish_endpoints")
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch ethernet interfaces with client: %v", err)
+		return nil, fmt.Errorf("failed to fetch inventory with client: %v", err)
 	}
+	eths := inventory.Interfaces
 
 	// check if we have the required params first
 	if eths == nil {
@@ -47,18 +59,17 @@ func (g *Warewulf) Generate(config *config.Config, params Params) (FileMap, erro
 		return nil, fmt.Errorf("no ethernet interfaces found")
 	}
 
-	// fetch redfish endpoints and handle errors
-	eps, err := smdClient.FetchRedfishEndpoints(params.Verbose)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch redfish endpoints: %v", err)
-	}
+	// check the redfish endpoints fetched with the interfaces
+	eps := inventory.RedfishEndpoints
 	if len(eps) <= 0 {
 		return nil, fmt.Errorf("no redfish endpoints found")
 	}
 
-	templates, err := ApplyTemplates(Mappings{
+	mappings := Mappings{
 		"node_entries": nodeEntries,
-	}, params.Templates)
+	}
+	maps.Copy(mappings, inventory.Mappings())
+	templates, err := ApplyTemplates(mappings, params.Templates)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load templates: %v", err)
 	}
@@ -4,17 +4,22 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"maps"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	configurator "github.com/OpenCHAMI/configurator/pkg"
 	"github.com/OpenCHAMI/configurator/pkg/client"
 	"github.com/OpenCHAMI/configurator/pkg/config"
 	"github.com/OpenCHAMI/configurator/pkg/generator"
+	"github.com/OpenCHAMI/configurator/pkg/storage"
+	"github.com/OpenCHAMI/configurator/pkg/util"
 	"github.com/OpenCHAMI/jwtauth/v5"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -35,18 +40,24 @@ type Jwks struct {
 }
 type Server struct {
 	*http.Server
-	Config          *config.Config
-	Jwks            Jwks `yaml:"jwks"`
-	GeneratorParams generator.Params
-	TokenAuth       *jwtauth.JWTAuth
-	Targets         map[string]Target
+	Config    *config.Config
+	Jwks      Jwks `yaml:"jwks"`
+	TokenAuth *jwtauth.JWTAuth
+	Cache     *client.Cache
+	Storage   storage.Storage
+
+	// Targets keyed by name that must only be accessed while holding
+	// targetsMutex once the server is serving requests
+	Targets      map[string]Target
+	targetsMutex sync.RWMutex
 }
 
-type Target struct {
-	Name       string               `json:"name"`
-	PluginPath string               `json:"plugin"`
-	Templates  []generator.Template `json:"templates"`
-}
+// Targets and the templates they use by name are the same as the ones kept
+// in storage.
+type (
+	Target      = storage.Target
+	TemplateRef = storage.TemplateRef
+)
 
 // Constructor to make a new server instance with an optional config.
 func New(conf *config.Config) *Server {
@@ -62,9 +73,13 @@ func New(conf *config.Config) *Server {
 			Uri:     conf.Server.Jwks.Uri,
 			Retries: conf.Server.Jwks.Retries,
 		},
+		Cache:   client.NewCache(conf.Server.CacheTTL),
+		Storage: storage.NewFileSystem(conf.Server.Storage),
 	}
 	// load templates for server from config
-	newServer.loadTargets()
+	if err := newServer.loadTargets(); err != nil {
+		log.Error().Err(err).Msg("failed to load targets")
+	}
 	log.Debug().Any("targets", newServer.Targets).Msg("new server targets")
 	return newServer
 }
@@ -73,7 +88,6 @@ func New(conf *config.Config) *Server {
 func (s *Server) Serve() error {
 	// Setup logger
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-	logger := log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
 	// set the server address with config values
 	s.Server.Addr = s.Config.Server.Host
@@ -91,12 +105,17 @@ func (s *Server) Serve() error {
 		}
 	}
 
+	s.Handler = s.NewRouter()
+	return s.ListenAndServe()
+}
+
+// Creates the router with every route for the server. Routes other than the
+// status are protected when a JWKS URI is set.
+func (s *Server) NewRouter() chi.Router {
+	logger := log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
 	// create client with opts to use to fetch data from SMD
-	opts := []client.Option{
-		client.WithHost(s.Config.SmdClient.Host),
-		client.WithAccessToken(s.Config.AccessToken),
-		client.WithCertPoolFile(s.Config.CertPath),
-	}
+	opts := append(s.Config.SmdClientOptions(), client.WithCache(s.Cache))
 
 	// create new go-chi router with its routes
 	router := chi.NewRouter()
@@ -115,20 +134,33 @@ func (s *Server) Serve() error {
 			)
 
 			// protected routes if using auth
-			r.HandleFunc("/generate", s.Generate(opts...))
-			r.Post("/targets", s.createTarget)
+			s.addRoutes(r, opts...)
 		})
 	} else {
 		// public routes without auth
-		router.HandleFunc("/generate", s.Generate(opts...))
-		router.Post("/targets", s.createTarget)
+		s.addRoutes(router, opts...)
 	}
 
 	// always available public routes go here (none at the moment)
 	router.HandleFunc("/configurator/status", s.GetStatus)
 
-	s.Handler = router
-	return s.ListenAndServe()
+	return router
+}
+
+// Adds the routes that are protected when using auth.
+func (s *Server) addRoutes(r chi.Router, opts ...client.Option) {
+	r.HandleFunc("/generate", s.Generate(opts...))
+	r.Get("/targets", s.listTargets)
+	r.Post("/targets", s.createTarget)
+	r.Get("/targets/{name}", s.getTarget)
+	r.Put("/targets/{name}", s.putTarget)
+	r.Delete("/targets/{name}", s.deleteTarget)
+	r.Get("/templates", s.listTemplates)
+	r.Post("/templates", s.createTemplate)
+	r.Get("/templates/{name}", s.getTemplate)
+	r.Put("/templates/{name}", s.putTemplate)
+	r.Delete("/templates/{name}", s.deleteTemplate)
+	r.Get("/templates/{name}/versions", s.getTemplateVersions)
 }
 
 // TODO: implement a way to shut the server down
@@ -144,11 +176,11 @@ func (s *Server) Generate(opts ...client.Option) func(w http.ResponseWriter, r *
 		// get all of the expect query URL params and validate
 		var (
 			targetParam string  = r.URL.Query().Get("target")
-			target      *Target = s.getTarget(targetParam)
+			target      *Target = s.findTarget(targetParam)
+			params      generator.Params
 			outputs     generator.FileMap
 			err         error
 		)
-		s.GeneratorParams = parseGeneratorParams(r, target, opts...)
 		if targetParam == "" {
 			err = writeErrorResponse(w, "must specify a target")
 			log.Error().Err(err).Msg("failed to parse generator params")
@@ -157,22 +189,54 @@ func (s *Server) Generate(opts ...client.Option) func(w http.ResponseWriter, r *
 
 		// try to generate with target supplied by client first
 		if target != nil {
+			// params are kept for each request so that concurrent requests
+			// never render with each other's templates
+			params = parseGeneratorParams(r, target, opts...)
+			params.BssClientOpts = append(s.Config.BssClientOptions(), client.WithCache(s.Cache))
+			params.Vars = s.Config.TemplateVars(targetParam)
+			templates, err := s.loadTemplateRefs(target.TemplateRefs)
+			if err != nil {
+				writeErrorResponse(w, "failed to load templates: %v", err)
+				log.Error().Err(err).Msg("failed to load templates")
+				return
+			}
+			maps.Copy(params.Templates, templates)
+
 			log.Debug().Any("target", target).Msg("target for Generate()")
-			outputs, err = generator.Generate(s.Config, target.PluginPath, s.GeneratorParams)
+			outputs, err = generator.Generate(s.Config, target.PluginPath, params)
 			log.Debug().Any("outputs map", outputs).Msgf("after generate")
 			if err != nil {
+				writeErrorResponse(w, "failed to generate file: %v", err)
 				log.Error().Err(err).Msg("failed to generate file")
 				return
 			}
 		} else {
 			// try and generate a new config file from supplied params
 			log.Debug().Str("target", targetParam).Msg("target for GenerateWithTarget()")
-			outputs, err = generator.GenerateWithTarget(s.Config, targetParam)
+			outputs, err = generator.GenerateWithTarget(s.Config, targetParam,
+				generator.WithCache(s.Cache),
+			)
+			if err != nil {
+				writeErrorResponse(w, "failed to generate file: %v", err)
+				log.Error().Err(err).Msgf("failed to generate file with target '%s'", targetParam)
+				return
+			}
+		}
+
+		// send the files as an archive if a format is requested
+		if format := r.URL.Query().Get("archive"); format != "" {
+			// key the outputs by their relative path like GenerateWithTarget()
+			outputs, err = generator.RelativeOutputs(outputs, params)
 			if err != nil {
-				writeErrorResponse(w, "failed to generate file")
-				log.Error().Err(err).Msgf("failed to generate file with target '%s'", target)
+				writeErrorResponse(w, "failed to create archive: %v", err)
+				log.Error().Err(err).Msg("failed to write archive response")
 				return
 			}
+			err = writeArchiveResponse(w, s.Config, targetParam, format, outputs)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to write archive response")
+			}
+			return
 		}
 
 		// marshal output to JSON then send response to client
@@ -192,7 +256,15 @@ func (s *Server) Generate(opts ...client.Option) func(w http.ResponseWriter, r *
 	}
 }
 
-func (s *Server) loadTargets() {
+// Loads the default generators, the targets from the config, and the targets
+// saved in storage as server targets. Targets with templates that fail to
+// load are not added and the errors are returned together after loading the
+// rest.
+func (s *Server) loadTargets() error {
+	s.targetsMutex.Lock()
+	defer s.targetsMutex.Unlock()
+
+	var errs []error
 	// make sure the map is initialized first
 	if s.Targets == nil {
 		s.Targets = make(map[string]Target)
@@ -217,13 +289,53 @@ func (s *Server) loadTargets() {
 			serverTarget.PluginPath = name
 		}
 		// add templates using template paths from config
-		for _, templatePath := range target.TemplatePaths {
-			template := generator.Template{}
-			template.LoadFromFile(templatePath)
-			serverTarget.Templates = append(serverTarget.Templates, template)
+		templates, err := s.loadTemplates(name, target)
+		if err != nil {
+			// skip only the override and keep the default generator target
+			if _, ok := generator.DefaultGenerators[name]; ok {
+				s.Targets[name] = Target{Name: name, PluginPath: name}
+			} else {
+				delete(s.Targets, name)
+			}
+			errs = append(errs, fmt.Errorf("failed to load target '%s': %w", name, err))
+			continue
 		}
+		serverTarget.Templates = templates
 		s.Targets[name] = serverTarget
 	}
+	// add targets created with the API (can't overwrite the targets above)
+	stored, err := s.Storage.ListTargets()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to load stored targets: %w", err))
+	}
+	for _, target := range stored {
+		if s.isConfigTarget(target.Name) {
+			errs = append(errs, fmt.Errorf("stored target '%s' is already set in the config", target.Name))
+			continue
+		}
+		s.Targets[target.Name] = s.prepareTarget(target)
+	}
+	return errors.Join(errs...)
+}
+
+// Loads the templates for a target from the config in the order they are set.
+func (s *Server) loadTemplates(name string, target configurator.Target) ([]generator.Template, error) {
+	templates := []generator.Template{}
+	for _, templatePath := range target.TemplatePaths {
+		template := generator.Template{
+			SearchPath: s.Config.TemplateSearchPath(name),
+			Strict:     s.Config.Strict,
+			Engine:     generator.Engine(target.Engine),
+		}
+		if err := template.LoadFromFile(templatePath); err != nil {
+			return nil, fmt.Errorf("failed to load template '%s': %w", templatePath, err)
+		}
+		if output, ok := target.Outputs[templatePath]; ok {
+			template.Output = &output
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
 }
 
 func (s *Server) GetStatus(w http.ResponseWriter, r *http.Request) {
@@ -239,86 +351,61 @@ func (s *Server) GetStatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Create a new target with name, generator, templates, and files.
-//
-// Example:
-//
-//	curl -X POST /target?name=test&plugin=dnsmasq
-//
-// TODO: need to implement template managing API first in "internal/generator/templates" or something
-func (s *Server) createTarget(w http.ResponseWriter, r *http.Request) {
-	var (
-		target = Target{}
-		bytes  []byte
-		err    error
-	)
-	if r == nil {
-		err = writeErrorResponse(w, "request is invalid")
-		log.Error().Err(err).Msg("request == nil")
-		return
-	}
-
-	bytes, err = io.ReadAll(r.Body)
-	if err != nil {
-		writeErrorResponse(w, "failed to read response body: %v", err)
-		log.Error().Err(err).Msg("failed to read response body")
-		return
-	}
-	defer r.Body.Close()
-
-	err = json.Unmarshal(bytes, &target)
-	if err != nil {
-		writeErrorResponse(w, "failed to unmarshal target: %v", err)
-		log.Error().Err(err).Msg("failed to unmarshal target")
-		return
-	}
-
-	// make sure a plugin and at least one template is supplied
-	if target.Name == "" {
-		err = writeErrorResponse(w, "target name is required")
-		log.Error().Err(err).Msg("set target as a URL query parameter")
-		return
-	}
-	if target.PluginPath == "" {
-		err = writeErrorResponse(w, "generator name is required")
-		log.Error().Err(err).Msg("must supply a generator name")
-		return
-	}
-	if len(target.Templates) <= 0 {
-		writeErrorResponse(w, "requires at least one template")
-		log.Error().Err(err).Msg("must supply at least one template")
-		return
-	}
-
-	s.Targets[target.Name] = target
-
-}
-
-func (s *Server) getTarget(name string) *Target {
-	t, ok := s.Targets[name]
-	if ok {
-		return &t
-	}
-	return nil
-}
-
 // Wrapper function to simplify writting error message responses. This function
 // is only intended to be used with the service and nothing else.
 func writeErrorResponse(w http.ResponseWriter, format string, a ...any) error {
+	return writeErrorResponseWithStatus(w, http.StatusInternalServerError, format, a...)
+}
+
+// Same as writeErrorResponse but with the status code set.
+func writeErrorResponseWithStatus(w http.ResponseWriter, status int, format string, a ...any) error {
 	errmsg := fmt.Sprintf(format, a...)
 	bytes, _ := json.Marshal(map[string]any{
 		"level":   "error",
 		"time":    time.Now().Unix(),
 		"message": errmsg,
 	})
-	http.Error(w, string(bytes), http.StatusInternalServerError)
+	http.Error(w, string(bytes), status)
 	return fmt.Errorf(errmsg)
 }
 
+// Writes the outputs keyed by their path relative to the output directory as
+// an archive in the format to the response with the mode set for the target.
+func writeArchiveResponse(w http.ResponseWriter, conf *config.Config, target string, format string, outputs generator.FileMap) error {
+	archiveFormat, err := util.ParseArchiveFormat(format)
+	if err != nil {
+		return writeErrorResponse(w, "%v", err)
+	}
+	targetInfo := conf.Targets[target]
+	mode, err := targetInfo.FileMode(0o644)
+	if err != nil {
+		return writeErrorResponse(w, "%v", err)
+	}
+	files := make([]util.ArchiveFile, 0, len(outputs))
+	for path, contents := range outputs {
+		files = append(files, util.ArchiveFile{Name: path, Contents: contents, Mode: mode})
+	}
+
+	// write the archive to a buffer first so that errors can still be sent
+	var b bytes.Buffer
+	err = util.WriteArchive(&b, archiveFormat, files)
+	if err != nil {
+		return writeErrorResponse(w, "failed to create archive: %v", err)
+	}
+	w.Header().Set("Content-Type", archiveFormat.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", target+archiveFormat.Extension()))
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
 func parseGeneratorParams(r *http.Request, target *Target, opts ...client.Option) generator.Params {
 	var params = generator.Params{
+		Context:    r.Context(),
 		ClientOpts: opts,
-		Templates:  make(map[string]generator.Template, len(target.Templates)),
+		Templates:  map[string]generator.Template{},
+	}
+	if target == nil {
+		return params
 	}
 	for i, template := range target.Templates {
 		params.Templates[fmt.Sprintf("%s_%d", target.Name, i)] = template
@@ -0,0 +1,230 @@
+//go:build server || all
+// +build server all
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+	"github.com/OpenCHAMI/configurator/pkg/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Lists every target including the default generators and the targets from
+// the config sorted by name.
+//
+// Example:
+//
+//	curl /targets
+func (s *Server) listTargets(w http.ResponseWriter, r *http.Request) {
+	s.targetsMutex.RLock()
+	targets := make([]Target, 0, len(s.Targets))
+	for _, target := range s.Targets {
+		targets = append(targets, target)
+	}
+	s.targetsMutex.RUnlock()
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Name < targets[j].Name
+	})
+	writeJSONResponse(w, http.StatusOK, targets)
+}
+
+// Returns the target with the name in the URL.
+//
+// Example:
+//
+//	curl /targets/dnsmasq
+func (s *Server) getTarget(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	target := s.findTarget(name)
+	if target == nil {
+		writeErrorResponseWithStatus(w, http.StatusNotFound, "target '%s' not found", name)
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, target)
+}
+
+// Create a new target with name, generator, templates, and files. Templates
+// created with the "/templates" API can be used by name with "template_refs".
+// The target is saved to storage so that it is loaded again when the server
+// restarts. Targets that already exist are replaced with "PUT /targets/{name}".
+//
+// Example:
+//
+//	curl -X POST /targets -d '{"name": "test", "plugin": "dnsmasq", "template_refs": [{"name": "dnsmasq.jinja"}]}'
+func (s *Server) createTarget(w http.ResponseWriter, r *http.Request) {
+	target, err := readTarget(r)
+	if err != nil {
+		writeErrorResponseWithStatus(w, http.StatusBadRequest, "%v", err)
+		log.Error().Err(err).Msg("failed to read target")
+		return
+	}
+	s.saveTarget(w, target, true)
+}
+
+// Replaces the target with the name in the URL or creates it if it doesn't
+// exist. The default generators and the targets from the config can't be
+// replaced.
+//
+// Example:
+//
+//	curl -X PUT /targets/test -d '{"plugin": "dnsmasq", "template_refs": [{"name": "dnsmasq.jinja"}]}'
+func (s *Server) putTarget(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	target, err := readTarget(r)
+	if err != nil {
+		writeErrorResponseWithStatus(w, http.StatusBadRequest, "%v", err)
+		log.Error().Err(err).Msg("failed to read target")
+		return
+	}
+	if target.Name != "" && target.Name != name {
+		writeErrorResponseWithStatus(w, http.StatusBadRequest, "target name '%s' does not match '%s'", target.Name, name)
+		return
+	}
+	target.Name = name
+	if s.isConfigTarget(name) {
+		writeErrorResponseWithStatus(w, http.StatusConflict, "target '%s' is set in the config and can't be changed", name)
+		return
+	}
+	s.saveTarget(w, target, false)
+}
+
+// Removes the target with the name in the URL from the server and storage.
+// The default generators and the targets from the config can't be removed.
+//
+// Example:
+//
+//	curl -X DELETE /targets/test
+func (s *Server) deleteTarget(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	s.targetsMutex.Lock()
+	defer s.targetsMutex.Unlock()
+
+	if _, ok := s.Targets[name]; !ok {
+		writeErrorResponseWithStatus(w, http.StatusNotFound, "target '%s' not found", name)
+		return
+	}
+	if s.isConfigTarget(name) {
+		writeErrorResponseWithStatus(w, http.StatusConflict, "target '%s' is set in the config and can't be removed", name)
+		return
+	}
+	if err := s.Storage.DeleteTarget(name); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		writeErrorResponse(w, "failed to remove target: %v", err)
+		log.Error().Err(err).Str("target", name).Msg("failed to remove target")
+		return
+	}
+	delete(s.Targets, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Checks the target, saves it to storage, and adds it to the server's
+// targets. Writes the target in the response with 201 if it was created or
+// 200 if it replaced a target. Targets that exist are a conflict when create
+// is set.
+func (s *Server) saveTarget(w http.ResponseWriter, target Target, create bool) {
+	// hold the lock until the target is added so that checking it can't be
+	// interleaved with another request adding it or removing its templates
+	s.targetsMutex.Lock()
+	defer s.targetsMutex.Unlock()
+
+	if code, err := s.validateTarget(target); err != nil {
+		writeErrorResponseWithStatus(w, code, "%v", err)
+		log.Error().Err(err).Msg("invalid target")
+		return
+	}
+
+	status := http.StatusCreated
+	if _, ok := s.Targets[target.Name]; ok {
+		if create {
+			writeErrorResponseWithStatus(w, http.StatusConflict, "target '%s' already exists", target.Name)
+			return
+		}
+		status = http.StatusOK
+	}
+	if err := s.Storage.PutTarget(target); err != nil {
+		writeErrorResponse(w, "failed to save target: %v", err)
+		log.Error().Err(err).Str("target", target.Name).Msg("failed to save target")
+		return
+	}
+	s.Targets[target.Name] = s.prepareTarget(target)
+	writeJSONResponse(w, status, target)
+}
+
+// Checks that the target has a name, a plugin, and at least one template and
+// that the templates it uses by name exist. Returns the status code to
+// respond with for the error.
+func (s *Server) validateTarget(target Target) (int, error) {
+	if target.Name == "" {
+		return http.StatusBadRequest, fmt.Errorf("target name is required")
+	}
+	if err := storage.ValidateName(target.Name); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if target.PluginPath == "" {
+		return http.StatusBadRequest, fmt.Errorf("generator name is required")
+	}
+	if len(target.Templates) <= 0 && len(target.TemplateRefs) <= 0 {
+		return http.StatusBadRequest, fmt.Errorf("requires at least one template")
+	}
+	if _, err := s.loadTemplateRefs(target.TemplateRefs); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return http.StatusNotFound, err
+		}
+		return http.StatusBadRequest, err
+	}
+	return http.StatusOK, nil
+}
+
+// Returns a copy of the target with its templates set to find included
+// templates using the global search path and to use strict mode from the
+// config.
+func (s *Server) prepareTarget(target Target) Target {
+	templates := make([]generator.Template, len(target.Templates))
+	for i, template := range target.Templates {
+		template.SearchPath = s.Config.TemplateSearchPath("")
+		template.Strict = s.Config.Strict
+		templates[i] = template
+	}
+	target.Templates = templates
+	return target
+}
+
+// Returns true if the target is a default generator or is set in the config.
+func (s *Server) isConfigTarget(name string) bool {
+	if _, ok := generator.DefaultGenerators[name]; ok {
+		return true
+	}
+	_, ok := s.Config.Targets[name]
+	return ok
+}
+
+func (s *Server) findTarget(name string) *Target {
+	s.targetsMutex.RLock()
+	defer s.targetsMutex.RUnlock()
+
+	t, ok := s.Targets[name]
+	if ok {
+		return &t
+	}
+	return nil
+}
+
+func readTarget(r *http.Request) (Target, error) {
+	var target Target
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return target, fmt.Errorf("failed to read request body: %v", err)
+	}
+	defer r.Body.Close()
+	if err := json.Unmarshal(b, &target); err != nil {
+		return target, fmt.Errorf("failed to unmarshal target: %v", err)
+	}
+	return target, nil
+}
@@ -0,0 +1,259 @@
+//go:build server || all
+// +build server all
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+	"github.com/OpenCHAMI/configurator/pkg/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Template returned after it is saved with the variables it uses.
+type templateResponse struct {
+	storage.Template
+	Variables []string `json:"variables"`
+}
+
+// Lists the latest version of every stored template without the contents.
+//
+// Example:
+//
+//	curl /templates
+func (s *Server) listTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := s.Storage.ListTemplates()
+	if err != nil {
+		writeErrorResponse(w, "failed to list templates: %v", err)
+		log.Error().Err(err).Msg("failed to list templates")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, templates)
+}
+
+// Returns the latest version of a template or the version set with the
+// "version" query parameter.
+//
+// Example:
+//
+//	curl /templates/dnsmasq.jinja?version=2
+func (s *Server) getTemplate(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    = chi.URLParam(r, "name")
+		version = 0
+		err     error
+	)
+	if param := r.URL.Query().Get("version"); param != "" {
+		version, err = strconv.Atoi(param)
+		if err != nil || version < 1 {
+			writeErrorResponseWithStatus(w, http.StatusBadRequest, "invalid version '%s'", param)
+			return
+		}
+	}
+	template, err := s.Storage.GetTemplate(name, version)
+	if err != nil {
+		writeStorageError(w, name, err)
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, template)
+}
+
+// Returns every version of a template from oldest to newest without the
+// contents.
+//
+// Example:
+//
+//	curl /templates/dnsmasq.jinja/versions
+func (s *Server) getTemplateVersions(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	versions, err := s.Storage.TemplateVersions(name)
+	if err != nil {
+		writeStorageError(w, name, err)
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, versions)
+}
+
+// Creates a new template with the name, contents, and engine in the body.
+// The template is parsed first and is not saved if it has syntax errors.
+// Templates that already exist are updated with "PUT /templates/{name}".
+//
+// Example:
+//
+//	curl -X POST /templates -d '{"name": "dnsmasq.jinja", "contents": "{{ dhcp_hosts }}"}'
+func (s *Server) createTemplate(w http.ResponseWriter, r *http.Request) {
+	template, err := readTemplate(r)
+	if err != nil {
+		writeErrorResponseWithStatus(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	if err := storage.ValidateName(template.Name); err != nil {
+		writeErrorResponseWithStatus(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	s.saveTemplate(w, template, http.StatusCreated, s.Storage.CreateTemplate)
+}
+
+// Saves a new version of the template with the name in the URL or creates
+// it if it doesn't exist. A new version is only saved if the contents or
+// engine changed.
+//
+// Example:
+//
+//	curl -X PUT /templates/dnsmasq.jinja -d '{"contents": "{{ dhcp_hosts }}"}'
+func (s *Server) putTemplate(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	template, err := readTemplate(r)
+	if err != nil {
+		writeErrorResponseWithStatus(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	if template.Name != "" && template.Name != name {
+		writeErrorResponseWithStatus(w, http.StatusBadRequest, "template name '%s' does not match '%s'", template.Name, name)
+		return
+	}
+	template.Name = name
+	if err := storage.ValidateName(name); err != nil {
+		writeErrorResponseWithStatus(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	status := http.StatusOK
+	if _, err := s.Storage.GetTemplate(name, 0); errors.Is(err, storage.ErrNotFound) {
+		status = http.StatusCreated
+	} else if err != nil {
+		writeStorageError(w, name, err)
+		return
+	}
+	s.saveTemplate(w, template, status, s.Storage.PutTemplate)
+}
+
+// Removes a template and every version of it. Templates used by a target
+// can't be removed.
+//
+// Example:
+//
+//	curl -X DELETE /templates/dnsmasq.jinja
+func (s *Server) deleteTemplate(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	// keep targets from using the template until it is removed
+	s.targetsMutex.RLock()
+	defer s.targetsMutex.RUnlock()
+
+	for _, target := range s.Targets {
+		for _, ref := range target.TemplateRefs {
+			if ref.Name == name {
+				writeErrorResponseWithStatus(w, http.StatusConflict, "template '%s' is used by target '%s'", name, target.Name)
+				return
+			}
+		}
+	}
+	if err := s.Storage.DeleteTemplate(name); err != nil {
+		writeStorageError(w, name, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Checks the template for syntax errors with its engine and saves it with
+// the storage function. The engine is set by the extension of the name if not
+// set.
+func (s *Server) saveTemplate(w http.ResponseWriter, template storage.Template, status int, save func(storage.Template) (storage.Template, error)) {
+	if template.Engine == "" {
+		template.Engine = string(generator.EngineFromPath(template.Name))
+	}
+	variables, err := generator.TemplateVariables(s.generatorTemplate(template))
+	if err != nil {
+		writeErrorResponseWithStatus(w, http.StatusBadRequest, "invalid template: %v", err)
+		return
+	}
+	template, err = save(template)
+	if errors.Is(err, storage.ErrExists) {
+		writeErrorResponseWithStatus(w, http.StatusConflict, "%v", err)
+		return
+	} else if err != nil {
+		writeErrorResponse(w, "failed to save template: %v", err)
+		log.Error().Err(err).Str("template", template.Name).Msg("failed to save template")
+		return
+	}
+	writeJSONResponse(w, status, templateResponse{Template: template, Variables: variables})
+}
+
+// Loads the templates a target uses by name from storage keyed by name.
+func (s *Server) loadTemplateRefs(refs []TemplateRef) (map[string]generator.Template, error) {
+	templates := make(map[string]generator.Template, len(refs))
+	for _, ref := range refs {
+		if _, ok := templates[ref.Name]; ok {
+			return nil, fmt.Errorf("template '%s' is used more than once", ref.Name)
+		}
+		stored, err := s.Storage.GetTemplate(ref.Name, ref.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get template '%s': %w", ref.Name, err)
+		}
+		template := s.generatorTemplate(stored)
+		template.Output = ref.Output
+		templates[ref.Name] = template
+	}
+	return templates, nil
+}
+
+// Converts a stored template to a template that can be rendered with the
+// global search path and strict mode from the config.
+func (s *Server) generatorTemplate(template storage.Template) generator.Template {
+	return generator.Template{
+		Contents:   []byte(template.Contents),
+		Engine:     generator.Engine(template.Engine),
+		SearchPath: s.Config.TemplateSearchPath(""),
+		Strict:     s.Config.Strict,
+	}
+}
+
+func readTemplate(r *http.Request) (storage.Template, error) {
+	var template storage.Template
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return template, fmt.Errorf("failed to read request body: %v", err)
+	}
+	defer r.Body.Close()
+	if err := json.Unmarshal(b, &template); err != nil {
+		return template, fmt.Errorf("failed to unmarshal template: %v", err)
+	}
+	return template, nil
+}
+
+// Writes a 404 if the template was not found, a 400 if the name is invalid,
+// or a 500 otherwise.
+func writeStorageError(w http.ResponseWriter, name string, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		writeErrorResponseWithStatus(w, http.StatusNotFound, "template '%s' not found", name)
+		return
+	case errors.Is(err, storage.ErrInvalidName):
+		writeErrorResponseWithStatus(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	writeErrorResponse(w, "failed to get template '%s': %v", name, err)
+	log.Error().Err(err).Str("template", name).Msg("failed to get template")
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeErrorResponse(w, "failed to marshal response: %v", err)
+		log.Error().Err(err).Msg("failed to marshal response")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err = w.Write(b); err != nil {
+		log.Error().Err(err).Msg("failed to write response")
+	}
+}
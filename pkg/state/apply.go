@@ -0,0 +1,253 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+const hooksPendingFile = "hooks-pending"
+
+// Writes the files keyed by their output path for the target in the meta,
+// keeps them as a new version if any changed, and runs the target's hooks.
+// The hooks are marked as pending before any file is written and the mark is
+// only removed once they succeed, so hooks that failed or were interrupted
+// are ran again by the next apply even if none of the files changed. Returns
+// whether any of the files changed.
+func (s *Store) Apply(meta Meta, target configurator.Target, files map[string][]byte) (bool, error) {
+	changed, err := ChangedFiles(files)
+	if err != nil {
+		return false, err
+	}
+	pending, err := s.HooksPending(meta.Target)
+	if err != nil {
+		return false, err
+	}
+	if len(changed) == 0 && !pending {
+		return false, nil
+	}
+
+	var saveErr error
+	if len(changed) > 0 {
+		if len(target.Hooks) > 0 {
+			err = s.SetHooksPending(meta.Target, true)
+			if err != nil {
+				return false, err
+			}
+		}
+		_, err = WriteFiles(target, files)
+		if err != nil {
+			return true, fmt.Errorf("failed to write files: %w", err)
+		}
+
+		// keep the applied files to be able to roll back later, but still run
+		// the hooks if they can't be kept since the files were written
+		meta, saveErr = s.Save(meta, files)
+		if saveErr != nil {
+			saveErr = fmt.Errorf("failed to save state: %w", saveErr)
+		} else {
+			log.Info().Str("target", meta.Target).Int("version", meta.Version).Msg("saved applied files")
+		}
+	} else {
+		log.Info().Str("target", meta.Target).Msg("retrying hooks that did not succeed")
+	}
+
+	err = s.runHooks(meta.Target, target.Hooks)
+	return len(changed) > 0, errors.Join(err, saveErr)
+}
+
+// Restores the files from a version saved for the target, saves them as the
+// latest version, and runs the target's hooks even if none of the files
+// changed. Returns the meta for the new version.
+func (s *Store) Restore(target configurator.Target, name string, version int) (Meta, error) {
+	meta, files, err := s.Load(name, version)
+	if err != nil {
+		return meta, err
+	}
+	if len(target.Hooks) > 0 {
+		err = s.SetHooksPending(name, true)
+		if err != nil {
+			return meta, err
+		}
+	}
+	_, err = WriteFiles(target, files)
+	if err != nil {
+		return meta, fmt.Errorf("failed to restore files: %w", err)
+	}
+
+	// save the restored files as the latest version for the version that
+	// was originally applied so that rolling back again skips over it
+	if meta.RollbackOf == 0 {
+		meta.RollbackOf = meta.Version
+	}
+	meta, saveErr := s.Save(meta, files)
+	if saveErr != nil {
+		saveErr = fmt.Errorf("failed to save state: %w", saveErr)
+	}
+
+	err = s.runHooks(name, target.Hooks)
+	return meta, errors.Join(err, saveErr)
+}
+
+// Returns the meta for the version to roll back to by default for the
+// target. This is the newest version applied before the version that is
+// currently applied, where restored versions count as the version that they
+// restored so that rolling back twice does not go back to the version that
+// was rolled back from.
+func (s *Store) Previous(target string) (Meta, error) {
+	versions, err := s.List(target)
+	if err != nil {
+		return Meta{}, err
+	}
+	if len(versions) == 0 {
+		return Meta{}, fmt.Errorf("no versions saved for target '%s'", target)
+	}
+	current := versions[len(versions)-1].applied()
+	for i := len(versions) - 2; i >= 0; i-- {
+		if versions[i].applied() < current {
+			return versions[i], nil
+		}
+	}
+	return Meta{}, fmt.Errorf("no previous version to roll back to for target '%s'", target)
+}
+
+// Returns the version that was originally applied with the files.
+func (m Meta) applied() int {
+	if m.RollbackOf != 0 {
+		return m.RollbackOf
+	}
+	return m.Version
+}
+
+// Runs the hooks for the target and removes the pending mark once they
+// succeed.
+func (s *Store) runHooks(target string, hooks []string) error {
+	err := RunHooks(hooks)
+	if err != nil {
+		return fmt.Errorf("failed to run hook: %w", err)
+	}
+	return s.SetHooksPending(target, false)
+}
+
+// Returns whether the hooks for the target still need to run because they
+// have not succeeded since its files last changed.
+func (s *Store) HooksPending(target string) (bool, error) {
+	targetDir, err := s.targetDir(target)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(filepath.Join(targetDir, hooksPendingFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to check pending hooks: %v", err)
+	}
+	return true, nil
+}
+
+// Marks or unmarks the hooks for the target as needing to run.
+func (s *Store) SetHooksPending(target string, pending bool) error {
+	targetDir, err := s.targetDir(target)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(targetDir, hooksPendingFile)
+	if !pending {
+		err = os.Remove(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to clear pending hooks: %v", err)
+		}
+		return nil
+	}
+	err = os.MkdirAll(targetDir, 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to make state directory: %v", err)
+	}
+	err = os.WriteFile(path, []byte{}, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to mark pending hooks: %v", err)
+	}
+	return nil
+}
+
+// Returns the sorted paths of the files keyed by their path that are
+// different from the files already on disk.
+func ChangedFiles(files map[string][]byte) ([]string, error) {
+	changed := []string{}
+	for path, contents := range files {
+		current, err := os.ReadFile(path)
+		if err == nil && bytes.Equal(current, contents) {
+			continue
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		changed = append(changed, path)
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// Writes each file keyed by its path that is different from the file already
+// on disk using the target's mode, owner, and group. Returns whether any of
+// the files changed.
+func WriteFiles(target configurator.Target, files map[string][]byte) (bool, error) {
+	uid, gid, err := util.LookupOwner(target.Owner, target.Group)
+	if err != nil {
+		return false, err
+	}
+
+	// sort so that the files are written in the same order every run
+	paths, err := ChangedFiles(files)
+	if err != nil {
+		return false, err
+	}
+	for i, path := range paths {
+		// keep the mode of existing files if one is not set for the target
+		fallback := os.FileMode(0o644)
+		info, err := os.Stat(path)
+		if err == nil {
+			fallback = info.Mode().Perm()
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return i > 0, fmt.Errorf("failed to stat file: %w", err)
+		}
+		mode, err := target.FileMode(fallback)
+		if err != nil {
+			return i > 0, err
+		}
+
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			return i > 0, fmt.Errorf("failed to make output directory: %w", err)
+		}
+		err = util.WriteFileAtomic(path, files[path], mode, uid, gid)
+		if err != nil {
+			return i > 0, err
+		}
+		log.Info().Msgf("wrote file to '%s'\n", path)
+	}
+	return len(paths) > 0, nil
+}
+
+// Runs each of the hook commands in order with the shell and stops at the
+// first one that fails.
+func RunHooks(hooks []string) error {
+	for _, hook := range hooks {
+		log.Info().Str("hook", hook).Msg("running hook")
+		c := exec.Command("sh", "-c", hook)
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+		if err := c.Run(); err != nil {
+			return fmt.Errorf("hook '%s' failed: %w", hook, err)
+		}
+	}
+	return nil
+}
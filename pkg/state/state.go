@@ -0,0 +1,192 @@
+// Package state keeps the last few versions of the files applied for each
+// target so that they can be restored later. Each version is stored in its
+// own directory under the target with a "meta.json" file:
+//
+//	<path>/<target>/<version>/meta.json
+//	<path>/<target>/<version>/<index>-<file name>
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const metaFile = "meta.json"
+
+// Information about a single version of the files applied for a target.
+type Meta struct {
+	Version          int               `json:"version"`
+	Target           string            `json:"target"`
+	GeneratorVersion string            `json:"generator_version"`
+	Timestamp        time.Time         `json:"timestamp"`
+	InventoryHash    string            `json:"inventory_hash"`
+	RollbackOf       int               `json:"rollback_of,omitempty"`
+	Files            map[string]string `json:"files"` // output path -> stored file name
+}
+
+// Stores versions of applied files in a directory and only keeps the most
+// recent versions for each target.
+type Store struct {
+	Path string
+	Keep int
+}
+
+// Creates a new store in the directory that keeps the last versions for
+// each target. A keep of zero or less keeps every version.
+func NewStore(path string, keep int) *Store {
+	return &Store{Path: path, Keep: keep}
+}
+
+// Saves the files keyed by their output path as a new version for the target
+// in the meta and removes the oldest versions past the number to keep. The
+// version and timestamp are set in the returned meta.
+func (s *Store) Save(meta Meta, files map[string][]byte) (Meta, error) {
+	targetDir, err := s.targetDir(meta.Target)
+	if err != nil {
+		return meta, err
+	}
+	versions, err := s.List(meta.Target)
+	if err != nil {
+		return meta, err
+	}
+
+	meta.Version = 1
+	if len(versions) > 0 {
+		meta.Version = versions[len(versions)-1].Version + 1
+	}
+	meta.Timestamp = time.Now().UTC()
+	meta.Files = make(map[string]string, len(files))
+
+	// write the version to a temporary directory first so that a partial
+	// version is never listed
+	err = os.MkdirAll(targetDir, 0o700)
+	if err != nil {
+		return meta, fmt.Errorf("failed to make state directory: %v", err)
+	}
+	tmpDir, err := os.MkdirTemp(targetDir, ".tmp")
+	if err != nil {
+		return meta, fmt.Errorf("failed to make temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for i, path := range paths {
+		name := fmt.Sprintf("%d-%s", i, filepath.Base(path))
+		err = os.WriteFile(filepath.Join(tmpDir, name), files[path], 0o600)
+		if err != nil {
+			return meta, fmt.Errorf("failed to write file: %v", err)
+		}
+		meta.Files[path] = name
+	}
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return meta, fmt.Errorf("failed to marshal meta: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(tmpDir, metaFile), b, 0o600)
+	if err != nil {
+		return meta, fmt.Errorf("failed to write meta: %v", err)
+	}
+	err = os.Rename(tmpDir, filepath.Join(targetDir, strconv.Itoa(meta.Version)))
+	if err != nil {
+		return meta, fmt.Errorf("failed to save version: %v", err)
+	}
+
+	// remove the oldest versions that we no longer need to keep
+	versions = append(versions, meta)
+	if s.Keep > 0 && len(versions) > s.Keep {
+		for _, old := range versions[:len(versions)-s.Keep] {
+			err = os.RemoveAll(filepath.Join(targetDir, strconv.Itoa(old.Version)))
+			if err != nil {
+				return meta, fmt.Errorf("failed to remove old version: %v", err)
+			}
+		}
+	}
+	return meta, nil
+}
+
+// Returns the meta for every version saved for the target from oldest to
+// newest. No versions are returned if nothing was saved for the target.
+func (s *Store) List(target string) ([]Meta, error) {
+	targetDir, err := s.targetDir(target)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(targetDir)
+	if os.IsNotExist(err) {
+		return []Meta{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read state directory: %v", err)
+	}
+
+	versions := []Meta{}
+	for _, entry := range entries {
+		version, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		meta, err := s.readMeta(target, version)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, meta)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+	return versions, nil
+}
+
+// Returns the meta and the files keyed by their output path for a version
+// saved for the target.
+func (s *Store) Load(target string, version int) (Meta, map[string][]byte, error) {
+	meta, err := s.readMeta(target, version)
+	if err != nil {
+		return meta, nil, err
+	}
+	targetDir, _ := s.targetDir(target)
+	files := make(map[string][]byte, len(meta.Files))
+	for path, name := range meta.Files {
+		b, err := os.ReadFile(filepath.Join(targetDir, strconv.Itoa(version), name))
+		if err != nil {
+			return meta, nil, fmt.Errorf("failed to read file: %v", err)
+		}
+		files[path] = b
+	}
+	return meta, files, nil
+}
+
+func (s *Store) readMeta(target string, version int) (Meta, error) {
+	var meta Meta
+	targetDir, err := s.targetDir(target)
+	if err != nil {
+		return meta, err
+	}
+	b, err := os.ReadFile(filepath.Join(targetDir, strconv.Itoa(version), metaFile))
+	if os.IsNotExist(err) {
+		return meta, fmt.Errorf("version %d not found for target '%s'", version, target)
+	} else if err != nil {
+		return meta, fmt.Errorf("failed to read meta: %v", err)
+	}
+	err = json.Unmarshal(b, &meta)
+	if err != nil {
+		return meta, fmt.Errorf("failed to unmarshal meta: %v", err)
+	}
+	return meta, nil
+}
+
+func (s *Store) targetDir(target string) (string, error) {
+	if target == "" || target == "." || target == ".." || strings.ContainsAny(target, `/\`) {
+		return "", fmt.Errorf("invalid target name '%s'", target)
+	}
+	return filepath.Join(s.Path, target), nil
+}
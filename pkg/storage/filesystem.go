@@ -0,0 +1,327 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stores each version of a template as a JSON file in a directory for the
+// template and each target as a JSON file:
+//
+//	<path>/templates/<name>/<version>.json
+//	<path>/targets/<name>.json
+type FileSystem struct {
+	Path string
+
+	mutex sync.Mutex
+}
+
+// Creates a new storage in the directory. The directory is created when the
+// first template or target is saved.
+func NewFileSystem(path string) *FileSystem {
+	return &FileSystem{Path: path}
+}
+
+func (fs *FileSystem) ListTemplates() ([]Template, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	entries, err := os.ReadDir(filepath.Join(fs.Path, "templates"))
+	if os.IsNotExist(err) {
+		return []Template{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read templates directory: %v", err)
+	}
+
+	templates := []Template{}
+	for _, entry := range entries {
+		if !entry.IsDir() || ValidateName(entry.Name()) != nil {
+			continue
+		}
+		template, err := fs.latest(entry.Name())
+		if err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		template.Contents = ""
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+func (fs *FileSystem) GetTemplate(name string, version int) (Template, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := ValidateName(name); err != nil {
+		return Template{}, err
+	}
+	if version == 0 {
+		return fs.latest(name)
+	}
+	return fs.read(name, version)
+}
+
+func (fs *FileSystem) TemplateVersions(name string) ([]Template, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	versions, err := fs.versions(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+	templates := make([]Template, 0, len(versions))
+	for _, version := range versions {
+		template, err := fs.read(name, version)
+		if err != nil {
+			return nil, err
+		}
+		template.Contents = ""
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+func (fs *FileSystem) PutTemplate(template Template) (Template, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := ValidateName(template.Name); err != nil {
+		return template, err
+	}
+	template.Hash = Hash(template.Contents)
+	template.Version = 1
+
+	// only save a new version if the template changed
+	latest, err := fs.latest(template.Name)
+	if err == nil {
+		if latest.Hash == template.Hash && latest.Engine == template.Engine {
+			return latest, nil
+		}
+		template.Version = latest.Version + 1
+	} else if err != ErrNotFound {
+		return template, err
+	}
+	template.Timestamp = time.Now().UTC()
+
+	dir := filepath.Join(fs.Path, "templates", template.Name)
+	err = writeJSON(dir, fmt.Sprintf("%d.json", template.Version), template)
+	if err != nil {
+		return template, fmt.Errorf("failed to save template: %v", err)
+	}
+	return template, nil
+}
+
+func (fs *FileSystem) CreateTemplate(template Template) (Template, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := ValidateName(template.Name); err != nil {
+		return template, err
+	}
+	if _, err := fs.latest(template.Name); err == nil {
+		return template, fmt.Errorf("template '%s' %w", template.Name, ErrExists)
+	} else if err != ErrNotFound {
+		return template, err
+	}
+	template.Hash = Hash(template.Contents)
+	template.Version = 1
+	template.Timestamp = time.Now().UTC()
+
+	dir := filepath.Join(fs.Path, "templates", template.Name)
+	err := writeJSON(dir, fmt.Sprintf("%d.json", template.Version), template)
+	if err != nil {
+		return template, fmt.Errorf("failed to save template: %v", err)
+	}
+	return template, nil
+}
+
+func (fs *FileSystem) DeleteTemplate(name string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	dir := filepath.Join(fs.Path, "templates", name)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove template: %v", err)
+	}
+	return nil
+}
+
+// Returns the versions saved for the template from oldest to newest.
+func (fs *FileSystem) versions(name string) ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(fs.Path, "templates", name))
+	if os.IsNotExist(err) {
+		return []int{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read template directory: %v", err)
+	}
+	versions := []int{}
+	for _, entry := range entries {
+		version, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+func (fs *FileSystem) latest(name string) (Template, error) {
+	versions, err := fs.versions(name)
+	if err != nil {
+		return Template{}, err
+	}
+	if len(versions) == 0 {
+		return Template{}, ErrNotFound
+	}
+	return fs.read(name, versions[len(versions)-1])
+}
+
+func (fs *FileSystem) read(name string, version int) (Template, error) {
+	var template Template
+	b, err := os.ReadFile(filepath.Join(fs.Path, "templates", name, fmt.Sprintf("%d.json", version)))
+	if os.IsNotExist(err) {
+		return template, ErrNotFound
+	} else if err != nil {
+		return template, fmt.Errorf("failed to read template: %v", err)
+	}
+	err = json.Unmarshal(b, &template)
+	if err != nil {
+		return template, fmt.Errorf("failed to unmarshal template: %v", err)
+	}
+	return template, nil
+}
+
+func (fs *FileSystem) ListTargets() ([]Target, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	entries, err := os.ReadDir(filepath.Join(fs.Path, "targets"))
+	if os.IsNotExist(err) {
+		return []Target{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read targets directory: %v", err)
+	}
+
+	targets := []Target{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() || ValidateName(name) != nil {
+			continue
+		}
+		target, err := fs.readTarget(name)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Name < targets[j].Name
+	})
+	return targets, nil
+}
+
+func (fs *FileSystem) GetTarget(name string) (Target, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := ValidateName(name); err != nil {
+		return Target{}, err
+	}
+	return fs.readTarget(name)
+}
+
+func (fs *FileSystem) PutTarget(target Target) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := ValidateName(target.Name); err != nil {
+		return err
+	}
+	err := writeJSON(filepath.Join(fs.Path, "targets"), target.Name+".json", target)
+	if err != nil {
+		return fmt.Errorf("failed to save target: %v", err)
+	}
+	return nil
+}
+
+func (fs *FileSystem) DeleteTarget(name string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(fs.Path, "targets", name+".json"))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to remove target: %v", err)
+	}
+	return nil
+}
+
+func (fs *FileSystem) readTarget(name string) (Target, error) {
+	var target Target
+	b, err := os.ReadFile(filepath.Join(fs.Path, "targets", name+".json"))
+	if os.IsNotExist(err) {
+		return target, ErrNotFound
+	} else if err != nil {
+		return target, fmt.Errorf("failed to read target: %v", err)
+	}
+	err = json.Unmarshal(b, &target)
+	if err != nil {
+		return target, fmt.Errorf("failed to unmarshal target: %v", err)
+	}
+	return target, nil
+}
+
+// Writes the value as JSON to a file in the directory. The value is written
+// to a temporary file first so that a partial file is never read.
+func writeJSON(dir string, name string, v any) error {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to make directory: %v", err)
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %v", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to make temporary file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
@@ -0,0 +1,122 @@
+// Package storage keeps the templates and targets created with the server's
+// API. Templates are kept by name along with every version of them. Storage
+// is an interface so that the server can keep them somewhere other than the
+// local filesystem.
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+)
+
+var (
+	// Returned when a template, one of its versions, or a target doesn't exist.
+	ErrNotFound = errors.New("not found")
+
+	// Returned when a name can't be used for a template or target.
+	ErrInvalidName = errors.New("invalid name")
+
+	// Returned when creating a template that already exists.
+	ErrExists = errors.New("already exists")
+)
+
+// A single version of a named template. The contents are left out when
+// listing templates and versions.
+type Template struct {
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	Hash      string    `json:"hash"`
+	Engine    string    `json:"engine,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Contents  string    `json:"contents,omitempty"`
+}
+
+// Target created with the server's API with the generator plugin and the
+// templates it uses.
+type Target struct {
+	Name       string               `json:"name"`
+	PluginPath string               `json:"plugin"`
+	Templates  []generator.Template `json:"templates"`
+
+	// Templates from storage used by name
+	TemplateRefs []TemplateRef `json:"template_refs,omitempty"`
+}
+
+// Stored template that a target uses by name. The latest version is used
+// each time the target is generated unless a version is set.
+type TemplateRef struct {
+	Name    string               `json:"name"`
+	Version int                  `json:"version,omitempty"`
+	Output  *configurator.Output `json:"output,omitempty"`
+}
+
+// Stores both templates and targets.
+type Storage interface {
+	TemplateStorage
+	TargetStorage
+}
+
+// Stores named templates and keeps every version of them.
+type TemplateStorage interface {
+	// Returns the latest version of every template without the contents
+	// sorted by name.
+	ListTemplates() ([]Template, error)
+
+	// Returns a version of the template with the contents or the latest
+	// version if the version is 0.
+	GetTemplate(name string, version int) (Template, error)
+
+	// Returns every version of the template from oldest to newest without
+	// the contents.
+	TemplateVersions(name string) ([]Template, error)
+
+	// Saves the template as a new version and returns it with the version,
+	// hash, and timestamp set. The latest version is returned instead if
+	// the contents and engine have not changed.
+	PutTemplate(template Template) (Template, error)
+
+	// Saves the template as the first version and returns it with the
+	// version, hash, and timestamp set. Returns ErrExists if a template with
+	// the same name already exists.
+	CreateTemplate(template Template) (Template, error)
+
+	// Removes the template and every version of it.
+	DeleteTemplate(name string) error
+}
+
+// Stores targets by name.
+type TargetStorage interface {
+	// Returns every target sorted by name.
+	ListTargets() ([]Target, error)
+
+	// Returns the target with the name.
+	GetTarget(name string) (Target, error)
+
+	// Saves the target replacing the target with the same name.
+	PutTarget(target Target) error
+
+	// Removes the target.
+	DeleteTarget(name string) error
+}
+
+// Returns the hash of the contents used to tell if a template has changed.
+func Hash(contents string) string {
+	sum := sha256.Sum256([]byte(contents))
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns an error if the name can't be used as a template or target name.
+// Names are used as file names so they can't be paths.
+func ValidateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%w '%s'", ErrInvalidName, name)
+	}
+	return nil
+}
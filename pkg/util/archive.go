@@ -0,0 +1,325 @@
+package util
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The formats supported when writing archives with WriteArchive().
+type ArchiveFormat string
+
+const (
+	ArchiveTarGz  ArchiveFormat = "tar.gz"
+	ArchiveTarZst ArchiveFormat = "tar.zst"
+	ArchiveZip    ArchiveFormat = "zip"
+)
+
+// The modification time set for every entry in an archive so that archives
+// written from the same files are the same. This is the earliest time that
+// can be stored in a zip archive.
+var archiveModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// A file written to an archive from memory. The name is the path of the file
+// inside of the archive and may contain directories separated by '/'.
+type ArchiveFile struct {
+	Name     string
+	Contents []byte
+	Mode     os.FileMode
+}
+
+// Returns the archive format from its name or an error if it is not supported.
+func ParseArchiveFormat(format string) (ArchiveFormat, error) {
+	switch f := ArchiveFormat(strings.TrimPrefix(strings.ToLower(format), ".")); f {
+	case ArchiveTarGz, ArchiveTarZst, ArchiveZip:
+		return f, nil
+	case "tgz":
+		return ArchiveTarGz, nil
+	case "tzst":
+		return ArchiveTarZst, nil
+	}
+	return "", fmt.Errorf("unsupported archive format '%s' (expected 'tar.gz', 'tar.zst', or 'zip')", format)
+}
+
+// Returns the file extension used for the archive format.
+func (f ArchiveFormat) Extension() string {
+	return "." + string(f)
+}
+
+// Returns the MIME type used when sending the archive in a HTTP response.
+func (f ArchiveFormat) ContentType() string {
+	switch f {
+	case ArchiveTarGz:
+		return "application/gzip"
+	case ArchiveTarZst:
+		return "application/zstd"
+	case ArchiveZip:
+		return "application/zip"
+	}
+	return "application/octet-stream"
+}
+
+// Returns the archive format for a MIME type returned by ContentType().
+func ArchiveFormatFromContentType(contentType string) (ArchiveFormat, bool) {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	for _, f := range []ArchiveFormat{ArchiveTarGz, ArchiveTarZst, ArchiveZip} {
+		if strings.TrimSpace(contentType) == f.ContentType() {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// Writes the files to an archive in the format without reading anything from
+// disk. Files are written in order by name with entries for each directory so
+// that the archive is the same every time for the same files. Files without
+// a mode are written with 0644.
+func WriteArchive(w io.Writer, format ArchiveFormat, files []ArchiveFile) error {
+	files, dirs, err := sortArchiveFiles(files)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case ArchiveTarGz:
+		gw := gzip.NewWriter(w)
+		if err := writeTar(gw, files, dirs); err != nil {
+			return err
+		}
+		return gw.Close()
+	case ArchiveTarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return fmt.Errorf("failed to create zstd writer: %v", err)
+		}
+		if err := writeTar(zw, files, dirs); err != nil {
+			zw.Close()
+			return err
+		}
+		return zw.Close()
+	case ArchiveZip:
+		return writeZip(w, files, dirs)
+	}
+	return fmt.Errorf("unsupported archive format '%s'", format)
+}
+
+func writeTar(w io.Writer, files []ArchiveFile, dirs []string) error {
+	tw := tar.NewWriter(w)
+	for _, dir := range dirs {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     dir + "/",
+			Mode:     0o755,
+			ModTime:  archiveModTime,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write directory to archive: %v", err)
+		}
+	}
+	for _, file := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.Name,
+			Mode:     int64(file.Mode.Perm()),
+			Size:     int64(len(file.Contents)),
+			ModTime:  archiveModTime,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write file header to archive: %v", err)
+		}
+		if _, err := tw.Write(file.Contents); err != nil {
+			return fmt.Errorf("failed to write file to archive: %v", err)
+		}
+	}
+	return tw.Close()
+}
+
+func writeZip(w io.Writer, files []ArchiveFile, dirs []string) error {
+	zw := zip.NewWriter(w)
+	for _, dir := range dirs {
+		header := &zip.FileHeader{Name: dir + "/", Modified: archiveModTime}
+		header.SetMode(os.ModeDir | 0o755)
+		if _, err := zw.CreateHeader(header); err != nil {
+			return fmt.Errorf("failed to write directory to archive: %v", err)
+		}
+	}
+	for _, file := range files {
+		header := &zip.FileHeader{Name: file.Name, Method: zip.Deflate, Modified: archiveModTime}
+		header.SetMode(file.Mode.Perm())
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to write file header to archive: %v", err)
+		}
+		if _, err := fw.Write(file.Contents); err != nil {
+			return fmt.Errorf("failed to write file to archive: %v", err)
+		}
+	}
+	return zw.Close()
+}
+
+// Extracts the files from an archive in the format into the directory. Every
+// file is kept inside of the directory and directories are created as needed.
+// Returns the paths of the files that were extracted.
+func ExtractArchive(r io.Reader, format ArchiveFormat, dir string) ([]string, error) {
+	var files []ArchiveFile
+	switch format {
+	case ArchiveTarGz:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %v", err)
+		}
+		defer gr.Close()
+		files, err = readTar(gr)
+		if err != nil {
+			return nil, err
+		}
+	case ArchiveTarZst:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %v", err)
+		}
+		defer zr.Close()
+		files, err = readTar(zr)
+		if err != nil {
+			return nil, err
+		}
+	case ArchiveZip:
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %v", err)
+		}
+		files, err = readZip(b)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported archive format '%s'", format)
+	}
+
+	return ExtractFiles(files, dir)
+}
+
+// Writes the files to the directory with their names as paths relative to
+// it. Names are cleaned first and absolute names or names with '..' are an
+// error so that nothing is written outside of the directory. Returns the path
+// of each file written.
+func ExtractFiles(files []ArchiveFile, dir string) ([]string, error) {
+	for _, file := range files {
+		if filepath.IsAbs(file.Name) || strings.HasPrefix(strings.ReplaceAll(file.Name, "\\", "/"), "/") {
+			return nil, fmt.Errorf("invalid file name '%s' is an absolute path", file.Name)
+		}
+	}
+	files, _, err := sortArchiveFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file.Name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return paths, fmt.Errorf("failed to make directory: %v", err)
+		}
+		if err := os.WriteFile(path, file.Contents, file.Mode.Perm()); err != nil {
+			return paths, fmt.Errorf("failed to write file: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func readTar(r io.Reader) ([]ArchiveFile, error) {
+	var (
+		tr    = tar.NewReader(r)
+		files = []ArchiveFile{}
+	)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %v", err)
+		}
+		// only regular files are extracted
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file from archive: %v", err)
+		}
+		files = append(files, ArchiveFile{Name: header.Name, Contents: contents, Mode: os.FileMode(header.Mode).Perm()})
+	}
+}
+
+func readZip(b []byte) ([]ArchiveFile, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %v", err)
+	}
+	files := []ArchiveFile{}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file in archive: %v", err)
+		}
+		contents, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file from archive: %v", err)
+		}
+		files = append(files, ArchiveFile{Name: f.Name, Contents: contents, Mode: f.Mode().Perm()})
+	}
+	return files, nil
+}
+
+// Cleans and sorts the files by name and returns every parent directory that
+// needs to be in the archive.
+func sortArchiveFiles(files []ArchiveFile) ([]ArchiveFile, []string, error) {
+	var (
+		sorted = make([]ArchiveFile, 0, len(files))
+		names  = map[string]bool{}
+		dirs   = map[string]bool{}
+	)
+	for _, file := range files {
+		slashed := strings.ReplaceAll(file.Name, "\\", "/")
+		name := strings.TrimPrefix(path.Clean("/"+slashed), "/")
+		if name == "" || slices.Contains(strings.Split(slashed, "/"), "..") {
+			return nil, nil, fmt.Errorf("invalid file name '%s' in archive", file.Name)
+		}
+		if names[name] {
+			return nil, nil, fmt.Errorf("more than one file named '%s' in archive", name)
+		}
+		names[name] = true
+		if file.Mode == 0 {
+			file.Mode = 0o644
+		}
+		file.Name = name
+		sorted = append(sorted, file)
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	dirList := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		dirList = append(dirList, dir)
+	}
+	sort.Strings(dirList)
+	return sorted, dirList, nil
+}
@@ -0,0 +1,188 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Number of unchanged lines shown around each change in a unified diff.
+const diffContextLines = 3
+
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+type diffEdit struct {
+	op   diffOp
+	line string
+}
+
+// Returns a unified diff between the old and new contents similar to the
+// output of `diff -u`. The names are used for the "---" and "+++" headers.
+// An empty string is returned if the contents are the same.
+func UnifiedDiff(oldName string, newName string, oldContents []byte, newContents []byte) string {
+	if string(oldContents) == string(newContents) {
+		return ""
+	}
+
+	var (
+		edits  = diffLines(splitLines(string(oldContents)), splitLines(string(newContents)))
+		out    strings.Builder
+		oldPos = make([]int, len(edits)+1)
+		newPos = make([]int, len(edits)+1)
+	)
+
+	// track the line number in each file before every edit
+	for i, e := range edits {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if e.op != diffInsert {
+			oldPos[i+1]++
+		}
+		if e.op != diffDelete {
+			newPos[i+1]++
+		}
+	}
+
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range diffHunks(edits) {
+		var oldCount, newCount int
+		for _, e := range edits[h[0]:h[1]] {
+			if e.op != diffInsert {
+				oldCount++
+			}
+			if e.op != diffDelete {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(oldPos[h[0]], oldCount), hunkRange(newPos[h[0]], newCount))
+		for _, e := range edits[h[0]:h[1]] {
+			prefix := " "
+			switch e.op {
+			case diffDelete:
+				prefix = "-"
+			case diffInsert:
+				prefix = "+"
+			}
+			out.WriteString(prefix + e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return out.String()
+}
+
+// Splits the contents into lines that keep their trailing newline so that a
+// missing newline at the end of the file is shown as a change.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// Groups the edits into hunks with the changes and surrounding context. Each
+// hunk is returned as the start and end index into the edits.
+func diffHunks(edits []diffEdit) [][2]int {
+	var hunks [][2]int
+	for i, e := range edits {
+		if e.op == diffEqual {
+			continue
+		}
+		start := max(i-diffContextLines, 0)
+		end := min(i+diffContextLines+1, len(edits))
+		// merge with the previous hunk if the context overlaps
+		if len(hunks) > 0 && start <= hunks[len(hunks)-1][1] {
+			hunks[len(hunks)-1][1] = end
+			continue
+		}
+		hunks = append(hunks, [2]int{start, end})
+	}
+	return hunks
+}
+
+// Returns the shortest edit script to turn a into b using Myers' algorithm.
+func diffLines(a []string, b []string) []diffEdit {
+	var (
+		n, m   = len(a), len(b)
+		offset = n + m
+		v      = make([]int, 2*offset+2)
+		trace  [][]int
+	)
+
+	// find the shortest path while saving each round to backtrack
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// backtrack through each round to build the edits in reverse
+	var (
+		edits []diffEdit
+		x, y  = n, m
+	)
+	for d := len(trace) - 1; d >= 0; d-- {
+		var (
+			v     = trace[d]
+			k     = x - y
+			prevK int
+		)
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, diffEdit{op: diffEqual, line: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, diffEdit{op: diffInsert, line: b[y-1]})
+			} else {
+				edits = append(edits, diffEdit{op: diffDelete, line: a[x-1]})
+			}
+			x, y = prevX, prevY
+		}
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
@@ -0,0 +1,77 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// Writes the contents to a temporary file in the same directory as the path
+// and renames it to the path so that readers never see a partially written
+// file. The mode is set on the file before the rename. The owner and group
+// are only changed if the uid and gid are not -1 like os.Chown.
+func WriteFileAtomic(path string, contents []byte, mode os.FileMode, uid int, gid int) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	// clean up the temporary file if anything fails before the rename
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to set file mode: %v", err)
+	}
+	if uid != -1 || gid != -1 {
+		if err := os.Chown(tmp.Name(), uid, gid); err != nil {
+			return fmt.Errorf("failed to set file owner: %v", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename temporary file: %v", err)
+	}
+	return nil
+}
+
+// Returns the uid and gid for the owner and group names or IDs. An empty
+// owner or group is returned as -1 to leave it unchanged.
+func LookupOwner(owner string, group string) (int, int, error) {
+	var (
+		uid = -1
+		gid = -1
+		err error
+	)
+	if owner != "" {
+		uid, err = strconv.Atoi(owner)
+		if err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return -1, -1, fmt.Errorf("failed to look up user: %v", err)
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+	}
+	if group != "" {
+		gid, err = strconv.Atoi(group)
+		if err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return -1, -1, fmt.Errorf("failed to look up group: %v", err)
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+	return uid, gid, nil
+}
@@ -0,0 +1,204 @@
+// Package watch runs a function whenever the inventory in SMD may have
+// changed. The function is ran on a polling interval and whenever a state
+// change notification (SCN) is received. Notifications that arrive close
+// together are debounced into a single run and failed runs are retried with
+// an exponential backoff.
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultInterval   = time.Minute
+	DefaultDebounce   = 2 * time.Second
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = 5 * time.Minute
+)
+
+// Runs a function on an interval and when notified of changes.
+type Watcher struct {
+	Interval   time.Duration
+	Debounce   time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	run    func(ctx context.Context, reason string) error
+	events chan string
+	mu     sync.Mutex
+	runs   int
+}
+
+type Option func(*Watcher)
+
+// Creates a new watcher that calls run with the reason for each run, which is
+// "startup", "interval", "retry" after a failed run, or the reason passed to
+// Notify.
+func NewWatcher(run func(ctx context.Context, reason string) error, opts ...Option) *Watcher {
+	w := &Watcher{
+		Interval:   DefaultInterval,
+		Debounce:   DefaultDebounce,
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+		run:        run,
+		events:     make(chan string, 1),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Sets how often to run when no notifications are received. An interval of
+// zero only runs when notified.
+func WithInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		w.Interval = interval
+	}
+}
+
+// Sets how long to wait after the last notification before running.
+func WithDebounce(debounce time.Duration) Option {
+	return func(w *Watcher) {
+		w.Debounce = debounce
+	}
+}
+
+// Sets the minimum and maximum time to wait before retrying a failed run.
+func WithBackoff(min time.Duration, max time.Duration) Option {
+	return func(w *Watcher) {
+		w.MinBackoff = min
+		w.MaxBackoff = max
+	}
+}
+
+// Notifies the watcher that something changed. Notifications are dropped if
+// one is already waiting to be handled since they will be debounced anyway.
+func (w *Watcher) Notify(reason string) {
+	select {
+	case w.events <- reason:
+	default:
+	}
+}
+
+// Returns the number of times that the function was ran.
+func (w *Watcher) Runs() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.runs
+}
+
+// Runs the function once immediately and then on each interval or
+// notification until the context is cancelled.
+func (w *Watcher) Start(ctx context.Context) error {
+	var (
+		failures = 0
+		reason   = "startup"
+		timeout  = "interval"
+		next     = time.NewTimer(0)
+		debounce = time.NewTimer(0)
+	)
+	defer next.Stop()
+	defer debounce.Stop()
+	<-debounce.C
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case r := <-w.events:
+			// wait until notifications stop coming in before running
+			reason = r
+			stopTimer(debounce)
+			debounce.Reset(w.Debounce)
+			log.Debug().Str("reason", r).Dur("debounce", w.Debounce).Msg("received change notification")
+			continue
+		case <-debounce.C:
+			stopTimer(next)
+		case <-next.C:
+			// any pending notification is handled by this run
+			stopTimer(debounce)
+			if reason == "" {
+				reason = timeout
+			}
+		}
+
+		start := time.Now()
+		err := w.run(ctx, reason)
+		w.mu.Lock()
+		w.runs++
+		w.mu.Unlock()
+		if err != nil {
+			failures++
+			backoff := w.backoff(failures)
+			log.Error().Err(err).Str("reason", reason).Int("failures", failures).Dur("backoff", backoff).Msg("watch run failed")
+			next.Reset(backoff)
+			timeout = "retry"
+		} else {
+			failures = 0
+			log.Info().Str("reason", reason).Dur("duration", time.Since(start)).Msg("watch run finished")
+			if w.Interval > 0 {
+				next.Reset(w.Interval)
+			}
+			timeout = "interval"
+		}
+		reason = ""
+	}
+}
+
+// Returns a handler that notifies the watcher when it receives a state
+// change notification from SMD. This can be subscribed to SMD's SCN service
+// or called directly by anything that knows that the inventory changed.
+func (w *Watcher) Handler() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var scn struct {
+			Components []string `json:"Components"`
+			State      string   `json:"State,omitempty"`
+			Flag       string   `json:"Flag,omitempty"`
+			Enabled    *bool    `json:"Enabled,omitempty"`
+			Role       string   `json:"Role,omitempty"`
+			SubRole    string   `json:"SubRole,omitempty"`
+		}
+		b, err := io.ReadAll(r.Body)
+		if err == nil && len(b) > 0 {
+			err = json.Unmarshal(b, &scn)
+		}
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to read state change notification")
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		log.Info().Strs("components", scn.Components).Str("state", scn.State).Msg("received state change notification")
+		w.Notify("scn")
+		rw.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (w *Watcher) backoff(failures int) time.Duration {
+	backoff := w.MinBackoff
+	for i := 1; i < failures && backoff < w.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, w.MaxBackoff)
+}
+
+// Stops the timer and drains the channel so that it can be reset.
+func stopTimer(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package xname parses, validates, and builds the component IDs (xnames)
+// used by SMD to identify hardware by its physical location. An xname is made
+// of a cabinet followed by the chassis, slot, BMC, and node numbers:
+//
+//	x1000          cabinet 1000
+//	x1000c0        chassis 0 in cabinet 1000
+//	x1000c0s7      slot 7 in chassis 0
+//	x1000c0s7b0    BMC 0 in slot 7
+//	x1000c0s7b0n1  node 1 managed by BMC 0
+package xname
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The type of component identified by an xname using the same names as SMD.
+type Type string
+
+const (
+	Cabinet Type = "Cabinet"
+	Chassis Type = "Chassis"
+	Slot    Type = "ComputeModule"
+	BMC     Type = "NodeBMC"
+	Node    Type = "Node"
+)
+
+// Types in order from the top of the physical hierarchy to the bottom.
+var types = []Type{Cabinet, Chassis, Slot, BMC, Node}
+
+var pattern = regexp.MustCompile(`^x(\d+)(?:c(\d+)(?:s(\d+)(?:b(\d+)(?:n(\d+))?)?)?)?$`)
+
+// A parsed xname. Only the numbers down to the level of the type are used
+// and the rest are always zero.
+type XName struct {
+	Type    Type
+	Cabinet int
+	Chassis int
+	Slot    int
+	BMC     int
+	Node    int
+}
+
+// Parses and validates the xname. Upper case letters and leading zeros are
+// allowed, but the xname is always normalized when converted back to a
+// string (e.g. "X01000C0" becomes "x1000c0").
+func Parse(s string) (XName, error) {
+	matches := pattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if matches == nil {
+		return XName{}, fmt.Errorf("invalid xname '%s'", s)
+	}
+	var x XName
+	for i, match := range matches[1:] {
+		if match == "" {
+			break
+		}
+		n, err := strconv.Atoi(match)
+		if err != nil {
+			return XName{}, fmt.Errorf("invalid xname '%s': %v", s, err)
+		}
+		x.setNumber(i, n)
+		x.Type = types[i]
+	}
+	return x, nil
+}
+
+// Returns whether the string is a valid xname.
+func IsValid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// Builds the xname for a cabinet.
+func NewCabinet(cabinet int) XName {
+	return XName{Type: Cabinet, Cabinet: cabinet}
+}
+
+// Builds the xname for a chassis in a cabinet.
+func NewChassis(cabinet, chassis int) XName {
+	return XName{Type: Chassis, Cabinet: cabinet, Chassis: chassis}
+}
+
+// Builds the xname for a slot in a chassis.
+func NewSlot(cabinet, chassis, slot int) XName {
+	return XName{Type: Slot, Cabinet: cabinet, Chassis: chassis, Slot: slot}
+}
+
+// Builds the xname for a BMC in a slot.
+func NewBMC(cabinet, chassis, slot, bmc int) XName {
+	return XName{Type: BMC, Cabinet: cabinet, Chassis: chassis, Slot: slot, BMC: bmc}
+}
+
+// Builds the xname for a node managed by a BMC.
+func NewNode(cabinet, chassis, slot, bmc, node int) XName {
+	return XName{Type: Node, Cabinet: cabinet, Chassis: chassis, Slot: slot, BMC: bmc, Node: node}
+}
+
+func (x XName) String() string {
+	var (
+		prefixes = []string{"x", "c", "s", "b", "n"}
+		numbers  = x.numbers()
+		b        strings.Builder
+	)
+	for i := 0; i <= x.level(); i++ {
+		b.WriteString(prefixes[i])
+		b.WriteString(strconv.Itoa(numbers[i]))
+	}
+	return b.String()
+}
+
+// Returns the xname one level up (e.g. node -> BMC -> slot). A cabinet has no
+// parent so false is returned instead.
+func (x XName) Parent() (XName, bool) {
+	level := x.level()
+	if level <= 0 {
+		return XName{}, false
+	}
+	return x.Ancestor(types[level-1])
+}
+
+// Returns the xname of the component with the type that contains this one
+// (e.g. the cabinet of a node). False is returned if the type is not above
+// or the same as the xname's type.
+func (x XName) Ancestor(t Type) (XName, bool) {
+	level := levelOf(t)
+	if level < 0 || level > x.level() {
+		return XName{}, false
+	}
+	ancestor := XName{Type: t}
+	for i, n := range x.numbers()[:level+1] {
+		ancestor.setNumber(i, n)
+	}
+	return ancestor, true
+}
+
+// Returns the xname one level down with the number (e.g. BMC -> node). A node
+// has no children so an error is returned instead.
+func (x XName) Child(n int) (XName, error) {
+	level := x.level()
+	if level < 0 || level >= len(types)-1 {
+		return XName{}, fmt.Errorf("'%s' cannot have children", x)
+	}
+	if n < 0 {
+		return XName{}, fmt.Errorf("invalid child number %d", n)
+	}
+	child := x
+	child.Type = types[level+1]
+	child.setNumber(level+1, n)
+	return child, nil
+}
+
+// Returns whether the other xname is somewhere below this one (e.g. a cabinet
+// contains every node in its chassis). An xname does not contain itself.
+func (x XName) Contains(other XName) bool {
+	if other.level() <= x.level() {
+		return false
+	}
+	ancestor, ok := other.Ancestor(x.Type)
+	return ok && ancestor == x
+}
+
+// Compares the xnames in physical order. Returns a negative number if a comes
+// before b, a positive number if a comes after b, and zero if they are equal.
+// A parent always comes before its children.
+func Compare(a, b XName) int {
+	var (
+		an = a.numbers()
+		bn = b.numbers()
+	)
+	for i := range types {
+		if i > a.level() || i > b.level() {
+			return a.level() - b.level()
+		}
+		if an[i] != bn[i] {
+			return an[i] - bn[i]
+		}
+	}
+	return 0
+}
+
+// Sorts the xnames in physical order.
+func Sort(xnames []XName) {
+	sort.SliceStable(xnames, func(i, j int) bool {
+		return Compare(xnames[i], xnames[j]) < 0
+	})
+}
+
+// Sorts the strings with xnames in physical order. Strings that are not valid
+// xnames are sorted after the xnames.
+func SortStrings(s []string) {
+	sort.SliceStable(s, func(i, j int) bool {
+		return Less(s[i], s[j])
+	})
+}
+
+// Returns whether the string a comes before b in physical order. Strings that
+// are not valid xnames come after the xnames in lexical order.
+func Less(a, b string) bool {
+	x, errA := Parse(a)
+	y, errB := Parse(b)
+	switch {
+	case errA != nil && errB != nil:
+		return a < b
+	case errA != nil || errB != nil:
+		return errB != nil
+	}
+	return Compare(x, y) < 0
+}
+
+func (x XName) numbers() []int {
+	return []int{x.Cabinet, x.Chassis, x.Slot, x.BMC, x.Node}
+}
+
+func (x *XName) setNumber(level int, n int) {
+	*[]*int{&x.Cabinet, &x.Chassis, &x.Slot, &x.BMC, &x.Node}[level] = n
+}
+
+func (x XName) level() int {
+	return levelOf(x.Type)
+}
+
+func levelOf(t Type) int {
+	for i, other := range types {
+		if other == t {
+			return i
+		}
+	}
+	return -1
+}
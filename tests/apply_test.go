@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/util"
+)
+
+// Test that files are written atomically with the mode from the target and
+// that no temporary files are left behind.
+func TestWriteFileAtomic(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		path   = filepath.Join(dir, "dnsmasq.conf")
+		target = configurator.Target{Mode: "0600"}
+	)
+
+	mode, err := target.FileMode(0o644)
+	if err != nil {
+		t.Fatalf("failed to get file mode: %v", err)
+	}
+	for _, contents := range []string{"first", "second"} {
+		err = util.WriteFileAtomic(path, []byte(contents), mode, -1, -1)
+		if err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read file: %v", err)
+		}
+		if string(b) != contents {
+			t.Errorf("expected file to contain '%s' but got '%s'", contents, string(b))
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected file mode 0600 but got %o", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the written file in directory but found %d entries", len(entries))
+	}
+}
+
+// Test that the target's file mode falls back when not set and is rejected
+// when it is not octal.
+func TestTargetFileMode(t *testing.T) {
+	mode, err := configurator.Target{}.FileMode(0o640)
+	if err != nil || mode != 0o640 {
+		t.Errorf("expected fallback mode 0640 but got %o (%v)", mode, err)
+	}
+	_, err = configurator.Target{Mode: "0999"}.FileMode(0o644)
+	if err == nil {
+		t.Error("expected an error for an invalid file mode")
+	}
+}
@@ -0,0 +1,242 @@
+package tests
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/configurator/pkg/client/smdtest"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/server"
+	"github.com/OpenCHAMI/configurator/pkg/util"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Test that archives are written from memory in each format with the output
+// paths and modes of the files preserved.
+func TestWriteArchive(t *testing.T) {
+	var (
+		files = []util.ArchiveFile{
+			{Name: "x1000c0s0b0n0/boot.ipxe", Contents: []byte("#!ipxe"), Mode: 0o600},
+			{Name: "dnsmasq.conf", Contents: []byte("dhcp-host=a4:bf:01:38:ee:66")},
+		}
+		expected = map[string]struct {
+			contents string
+			mode     os.FileMode
+		}{
+			"x1000c0s0b0n0/":          {"", os.ModeDir | 0o755},
+			"x1000c0s0b0n0/boot.ipxe": {"#!ipxe", 0o600},
+			"dnsmasq.conf":            {"dhcp-host=a4:bf:01:38:ee:66", 0o644},
+		}
+	)
+
+	for _, format := range []util.ArchiveFormat{util.ArchiveTarGz, util.ArchiveTarZst, util.ArchiveZip} {
+		t.Run(string(format), func(t *testing.T) {
+			t.Parallel()
+			var b bytes.Buffer
+			if err := util.WriteArchive(&b, format, files); err != nil {
+				t.Fatalf("failed to write archive: %v", err)
+			}
+			entries := readArchive(t, format, b.Bytes())
+			if len(entries) != len(expected) {
+				t.Errorf("expected %d entries in archive but got %d", len(expected), len(entries))
+			}
+			for name, e := range expected {
+				entry, ok := entries[name]
+				if !ok {
+					t.Errorf("missing '%s' in archive", name)
+					continue
+				}
+				if entry.contents != e.contents || entry.mode != e.mode {
+					t.Errorf("expected '%s' with mode %v but got '%s' with mode %v", e.contents, e.mode, entry.contents, entry.mode)
+				}
+			}
+
+			// the archive is the same when it is written again later
+			time.Sleep(time.Second)
+			var again bytes.Buffer
+			if err := util.WriteArchive(&again, format, files); err != nil {
+				t.Fatalf("failed to write archive: %v", err)
+			}
+			if !bytes.Equal(b.Bytes(), again.Bytes()) {
+				t.Error("expected the same archive when written again with the same files")
+			}
+		})
+	}
+
+	// file names that would be extracted outside of the archive are errors
+	var b bytes.Buffer
+	if err := util.WriteArchive(&b, util.ArchiveTarGz, []util.ArchiveFile{{Name: "../hosts"}}); err == nil {
+		t.Error("expected an error for a file name with '..'")
+	}
+}
+
+type archiveEntry struct {
+	contents string
+	mode     os.FileMode
+}
+
+func readArchive(t *testing.T, format util.ArchiveFormat, b []byte) map[string]archiveEntry {
+	t.Helper()
+	entries := map[string]archiveEntry{}
+	if format == util.ArchiveZip {
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("failed to read zip: %v", err)
+		}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("failed to open file in zip: %v", err)
+			}
+			contents, _ := io.ReadAll(rc)
+			rc.Close()
+			entries[f.Name] = archiveEntry{string(contents), f.Mode()}
+		}
+		return entries
+	}
+
+	var (
+		r   io.Reader
+		err error
+	)
+	if format == util.ArchiveTarGz {
+		r, err = gzip.NewReader(bytes.NewReader(b))
+	} else {
+		r, err = zstd.NewReader(bytes.NewReader(b))
+	}
+	if err != nil {
+		t.Fatalf("failed to decompress archive: %v", err)
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("failed to read tar: %v", err)
+		}
+		contents, _ := io.ReadAll(tr)
+		entries[header.Name] = archiveEntry{string(contents), header.FileInfo().Mode()}
+	}
+	return entries
+}
+
+// Test that archives are extracted with their paths and modes preserved.
+func TestExtractArchive(t *testing.T) {
+	var (
+		b     bytes.Buffer
+		dir   = t.TempDir()
+		files = []util.ArchiveFile{
+			{Name: "x1000c0s0b0n0/boot.ipxe", Contents: []byte("#!ipxe"), Mode: 0o600},
+			{Name: "dnsmasq.conf", Contents: []byte("dhcp-host=a4:bf:01:38:ee:66")},
+		}
+	)
+	if err := util.WriteArchive(&b, util.ArchiveTarGz, files); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	format, ok := util.ArchiveFormatFromContentType(util.ArchiveTarGz.ContentType())
+	if !ok || format != util.ArchiveTarGz {
+		t.Fatalf("expected format '%s' from content type but got '%s'", util.ArchiveTarGz, format)
+	}
+	paths, err := util.ExtractArchive(&b, format, dir)
+	if err != nil {
+		t.Fatalf("failed to extract archive: %v", err)
+	}
+	if len(paths) != len(files) {
+		t.Errorf("expected %d files to be extracted but got %d", len(files), len(paths))
+	}
+	info, err := os.Stat(filepath.Join(dir, "x1000c0s0b0n0", "boot.ipxe"))
+	if err != nil {
+		t.Fatalf("failed to stat extracted file: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected file mode 0600 but got %o", info.Mode().Perm())
+	}
+}
+
+// Test that files are written to their cleaned paths relative to the
+// directory and that paths outside of the directory are errors.
+func TestExtractFiles(t *testing.T) {
+	dir := t.TempDir()
+	paths, err := util.ExtractFiles([]util.ArchiveFile{
+		{Name: "templates/dnsmasq.jinja", Contents: []byte("dhcp-host=a4:bf:01:38:ee:66")},
+		{Name: "./x1000c0s0b0n0//boot.ipxe", Contents: []byte("#!ipxe")},
+	}, dir)
+	if err != nil {
+		t.Fatalf("failed to write files: %v", err)
+	}
+	expected := []string{
+		filepath.Join(dir, "templates", "dnsmasq.jinja"),
+		filepath.Join(dir, "x1000c0s0b0n0", "boot.ipxe"),
+	}
+	if !slices.Equal(paths, expected) {
+		t.Errorf("expected files to be written to %v but got %v", expected, paths)
+	}
+
+	for _, name := range []string{"../hosts", "templates/../../hosts", "/etc/hosts", ""} {
+		if _, err := util.ExtractFiles([]util.ArchiveFile{{Name: name}}, dir); err == nil {
+			t.Errorf("expected an error writing '%s'", name)
+		}
+	}
+	if _, err := util.ExtractFiles([]util.ArchiveFile{{Name: "hosts"}, {Name: "./hosts"}}, dir); err == nil {
+		t.Error("expected an error writing two files to the same path")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "hosts")); err == nil {
+		t.Error("expected nothing to be written outside of the directory")
+	}
+}
+
+// Test that the archive returned by the server keeps the output paths set for
+// the templates of a target created with the API.
+func TestGenerateArchiveWithTemplateRefs(t *testing.T) {
+	var (
+		conf   = config.New()
+		smd    = smdtest.NewServer(smdtest.DefaultFixtures())
+		target = `{"name": "refs", "plugin": "dnsmasq", "template_refs": [` +
+			`{"name": "a.jinja", "output": {"path": "x/hosts"}}, ` +
+			`{"name": "b.jinja", "output": {"path": "y/hosts"}}]}`
+	)
+	defer smd.Close()
+	conf.SmdClient.Host = smd.URL
+	conf.Server.Storage = t.TempDir()
+	s := httptest.NewServer(server.New(&conf).NewRouter())
+	defer s.Close()
+
+	for _, request := range []struct{ path, body string }{
+		{"/templates", `{"name": "a.jinja", "contents": "a {{ plugin_name }}"}`},
+		{"/templates", `{"name": "b.jinja", "contents": "b {{ plugin_name }}"}`},
+		{"/targets", target},
+	} {
+		status, body, err := sendRequest(http.MethodPost, s.URL+request.path, request.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != http.StatusCreated {
+			t.Fatalf("expected POST %s to return %d but got %d: %s", request.path, http.StatusCreated, status, body)
+		}
+	}
+
+	status, body, err := sendRequest(http.MethodGet, s.URL+"/generate?target=refs&archive=tar.gz", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK {
+		t.Fatalf("expected the archive to be returned but got %d: %s", status, body)
+	}
+	entries := readArchive(t, util.ArchiveTarGz, []byte(body))
+	for name, expected := range map[string]string{"x/hosts": "a dnsmasq", "y/hosts": "b dnsmasq"} {
+		if entry, ok := entries[name]; !ok || entry.contents != expected {
+			t.Errorf("expected '%s' in archive with '%s' but got '%s'", name, expected, entry.contents)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/client/smdtest"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+)
+
+// Test joining the boot parameters with the interfaces when the parameters
+// are set by host or by MAC address and nodes have more than one interface.
+func TestJoinBootParameters(t *testing.T) {
+	var (
+		eths = []configurator.EthernetInterface{
+			{MacAddress: "A4:BF:01:38:EE:66", ComponentId: "x1000c0s0b0n0", IpAddresses: []configurator.IPAddr{{IpAddress: "172.16.0.1"}}},
+			{MacAddress: "a4:bf:01:38:ee:67", ComponentId: "x1000c0s0b0n0"},
+			{MacAddress: "a4:bf:01:38:ee:76", ComponentId: "x1000c0s1b0n0"},
+			{MacAddress: "a4:bf:01:38:ee:86", ComponentId: "x1000c0s2b0n0"},
+		}
+		tests = []struct {
+			name       string
+			bootParams []configurator.BootParameters
+			expected   []generator.BootEntry
+		}{
+			{
+				name:       "host keyed with multiple interfaces",
+				bootParams: []configurator.BootParameters{{Hosts: []string{"x1000c0s0b0n0"}, Kernel: "vmlinuz"}},
+				expected: []generator.BootEntry{
+					{Xname: "x1000c0s0b0n0", MAC: "a4:bf:01:38:ee:66", IP: "172.16.0.1", Kernel: "vmlinuz", Label: "x1000c0s0b0n0-a4bf0138ee66"},
+					{Xname: "x1000c0s0b0n0", MAC: "a4:bf:01:38:ee:67", Kernel: "vmlinuz", Label: "x1000c0s0b0n0-a4bf0138ee67"},
+				},
+			},
+			{
+				name:       "MAC keyed ignores case",
+				bootParams: []configurator.BootParameters{{Macs: []string{"A4:BF:01:38:EE:76"}, Kernel: "vmlinuz"}},
+				expected: []generator.BootEntry{
+					{Xname: "x1000c0s1b0n0", MAC: "a4:bf:01:38:ee:76", Kernel: "vmlinuz", Label: "x1000c0s1b0n0-a4bf0138ee76"},
+				},
+			},
+			{
+				name: "MAC keyed takes precedence over host keyed",
+				bootParams: []configurator.BootParameters{
+					{Hosts: []string{"x1000c0s0b0n0"}, Kernel: "vmlinuz"},
+					{Macs: []string{"a4:bf:01:38:ee:67"}, Kernel: "vmlinuz-debug"},
+				},
+				expected: []generator.BootEntry{
+					{Xname: "x1000c0s0b0n0", MAC: "a4:bf:01:38:ee:66", IP: "172.16.0.1", Kernel: "vmlinuz", Label: "x1000c0s0b0n0-a4bf0138ee66"},
+					{Xname: "x1000c0s0b0n0", MAC: "a4:bf:01:38:ee:67", Kernel: "vmlinuz-debug", Label: "x1000c0s0b0n0-a4bf0138ee67"},
+				},
+			},
+			{
+				name:       "no matching parameters",
+				bootParams: []configurator.BootParameters{{Hosts: []string{"x9000c0s0b0n0"}, Kernel: "vmlinuz"}},
+				expected:   []generator.BootEntry{},
+			},
+		}
+	)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries := generator.JoinBootParameters(test.bootParams, eths)
+			if len(entries) != len(test.expected) {
+				t.Fatalf("expected %d entries but got %+v", len(test.expected), entries)
+			}
+			for i, entry := range entries {
+				if entry != test.expected[i] {
+					t.Errorf("expected entry %d to be %+v but got %+v", i, test.expected[i], entry)
+				}
+			}
+		})
+	}
+}
+
+// Test that a node with more than one interface gets a unique iPXE label for
+// each of them.
+func TestGenerateBootParamsWithMultipleInterfaces(t *testing.T) {
+	var (
+		conf     = config.New()
+		fixtures = smdtest.DefaultFixtures()
+	)
+	second := fixtures.EthernetInterfaces[1]
+	second.MacAddress = "a4:bf:01:38:ee:67"
+	fixtures.EthernetInterfaces = append(fixtures.EthernetInterfaces, second)
+	fixtures.BootParameters = []configurator.BootParameters{{Hosts: []string{second.ComponentId}, Kernel: "vmlinuz"}}
+	s := smdtest.NewServer(fixtures)
+	defer s.Close()
+
+	fileMap, err := generator.DefaultGenerators["bootparams"].Generate(&conf, fakeSmdParams(s, "{{ ipxe_entries }}"))
+	if err != nil {
+		t.Fatalf("failed to generate file: %v", err)
+	}
+	labels := map[string]bool{}
+	for _, line := range strings.Split(string(fileMap["test"]), "\n") {
+		if !strings.HasPrefix(line, ":") {
+			continue
+		}
+		if labels[line] {
+			t.Errorf("expected each label to be unique but found '%s' more than once", line)
+		}
+		labels[line] = true
+	}
+	if len(labels) != 2 {
+		t.Errorf("expected a label for each interface but got:\n%s", fileMap["test"])
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/configurator/pkg/client"
+)
+
+// Test that lists are decoded from responses that are bare arrays or objects
+// with the list stored under a key.
+func TestFetchListResponses(t *testing.T) {
+	var tests = []struct {
+		name     string
+		body     string
+		expected int
+		fails    bool
+	}{
+		{"bare array", `[{"ID": "x1000c0s0b0n0"}]`, 1, false},
+		{"wrapped object", `{"Components": [{"ID": "x1000c0s0b0n0"}, {"ID": "x1000c0s1b0n0"}]}`, 2, false},
+		{"wrapped object with lowercase key", `{"components": [{"ID": "x1000c0s0b0n0"}]}`, 1, false},
+		{"wrapped null", `{"Components": null}`, 0, false},
+		{"missing key", `{"RedfishEndpoints": [{"ID": "x1000c0s0b0"}]}`, 0, false},
+		{"null", `null`, 0, false},
+		{"empty", ``, 0, false},
+		{"not a list", `"x1000c0s0b0n0"`, 0, true},
+		{"wrapped object that is not a list", `{"Components": {"ID": "x1000c0s0b0n0"}}`, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(test.body))
+			}))
+			defer s.Close()
+
+			smdClient, err := client.NewSmdClient(client.WithHost(s.URL))
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+			components, err := smdClient.FetchComponents(context.Background(), false)
+			if test.fails {
+				if err == nil {
+					t.Errorf("expected an error but got %d components", len(components))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to fetch components: %v", err)
+			}
+			if components == nil || len(components) != test.expected {
+				t.Errorf("expected %d components but got %+v", test.expected, components)
+			}
+		})
+	}
+}
+
+// Test that responses with a non-2xx status code are returned as a
+// *client.StatusError with the status code and response body.
+func TestFetchStatusError(t *testing.T) {
+	var tests = []struct {
+		status   int
+		expected error
+	}{
+		{http.StatusNotFound, client.ErrClientError},
+		{http.StatusForbidden, client.ErrClientError},
+		{http.StatusServiceUnavailable, client.ErrServerError},
+	}
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "no components", test.status)
+			}))
+			defer s.Close()
+
+			smdClient, err := client.NewSmdClient(client.WithHost(s.URL), client.WithRetries(1, time.Millisecond))
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+			_, err = smdClient.FetchComponents(context.Background(), false)
+			var statusErr *client.StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("expected a status error but got: %v", err)
+			}
+			if statusErr.StatusCode != test.status || string(statusErr.Body) != "no components\n" {
+				t.Errorf("expected status %d with the response body but got %d: %q", test.status, statusErr.StatusCode, statusErr.Body)
+			}
+			if !errors.Is(err, test.expected) {
+				t.Errorf("expected '%v' but got: %v", test.expected, err)
+			}
+		})
+	}
+}
+
+// Test that clients are not created when the proxy or CA bundle can't be used
+// instead of falling back to the defaults.
+func TestNewClientWithInvalidOptions(t *testing.T) {
+	var (
+		dir      = t.TempDir()
+		notACert = filepath.Join(dir, "ca.pem")
+		tests    = []struct {
+			name string
+			opt  client.Option
+		}{
+			{"proxy that is not a URL", client.WithProxy("http://proxy:port")},
+			{"proxy without a scheme", client.WithProxy("proxy.example.com")},
+			{"missing CA bundle", client.WithCertPoolFile(filepath.Join(dir, "missing.pem"))},
+			{"CA bundle without certificates", client.WithCertPoolFile(notACert)},
+		}
+	)
+	if err := os.WriteFile(notACert, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("failed to write CA bundle: %v", err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := client.NewSmdClient(client.WithHost("http://smd"), test.opt); err == nil {
+				t.Error("expected an error creating the SMD client")
+			}
+			if _, err := client.NewBssClient(client.WithHost("http://bss"), test.opt); err == nil {
+				t.Error("expected an error creating the BSS client")
+			}
+		})
+	}
+
+	// the defaults are used when the proxy and CA bundle are not set
+	if _, err := client.NewSmdClient(client.WithProxy(""), client.WithCertPoolFile("")); err != nil {
+		t.Errorf("expected the client to be created without a proxy or CA bundle but got: %v", err)
+	}
+}
+
+// Test that certificates are verified with a CA pool set unless the client is
+// explicitly set to be insecure.
+func TestClientInsecure(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	var tests = []struct {
+		name     string
+		opts     []client.Option
+		expected bool
+	}{
+		{"CA pool without the server's CA", []client.Option{client.WithCertPool(x509.NewCertPool())}, false},
+		{"CA pool and insecure", []client.Option{client.WithCertPool(x509.NewCertPool()), client.WithInsecure(true)}, true},
+		{"insecure without a CA pool", []client.Option{client.WithInsecure(true)}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params, err := client.ToParams(test.opts...)
+			if err != nil {
+				t.Fatalf("failed to set client options: %v", err)
+			}
+			httpClient := params.NewHTTPClient()
+			res, err := httpClient.Get(s.URL)
+			if err == nil {
+				res.Body.Close()
+			}
+			if test.expected && err != nil {
+				t.Errorf("expected the request to succeed but got: %v", err)
+			} else if !test.expected && err == nil {
+				t.Error("expected the request to fail verifying the certificate")
+			}
+		})
+	}
+}
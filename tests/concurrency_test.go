@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/OpenCHAMI/configurator/pkg/client"
+	"github.com/OpenCHAMI/configurator/pkg/client/smdtest"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/server"
+)
+
+// Test that parallel requests to generate different targets only render
+// their own templates while the targets are being replaced. Run with '-race'
+// to also check the server for data races.
+func TestConcurrentGenerate(t *testing.T) {
+	var (
+		conf     = config.New()
+		smd      = smdtest.NewServer(smdtest.DefaultFixtures())
+		targets  = 8
+		requests = 10
+		wg       sync.WaitGroup
+	)
+	defer smd.Close()
+	conf.SmdClient.Host = smd.URL
+	conf.Server.Storage = t.TempDir()
+	srv := server.New(&conf)
+	s := httptest.NewServer(srv.NewRouter())
+	defer s.Close()
+
+	// each target uses a template by name that contains the target's name
+	bodies := make([]string, targets)
+	for i := range bodies {
+		template := fmt.Sprintf(`{"contents": "target-%d"}`, i)
+		if status, body, err := sendRequest(http.MethodPut, fmt.Sprintf("%s/templates/target-%d.jinja", s.URL, i), template); err != nil || status != http.StatusCreated {
+			t.Fatalf("failed to create template: %d: %s (%v)", status, body, err)
+		}
+		bodies[i] = fmt.Sprintf(`{"plugin": "dnsmasq", "template_refs": [{"name": "target-%d.jinja"}]}`, i)
+		if status, body, err := sendRequest(http.MethodPut, fmt.Sprintf("%s/targets/target-%d", s.URL, i), bodies[i]); err != nil || status != http.StatusCreated {
+			t.Fatalf("failed to create target: %d: %s (%v)", status, body, err)
+		}
+	}
+
+	// call the generate handler directly so that the requests overlap with
+	// the targets being replaced as much as possible
+	generate := srv.Generate(append(conf.SmdClientOptions(), client.WithCache(srv.Cache))...)
+	errs := make(chan error, targets*requests*2)
+	for i := 0; i < targets; i++ {
+		for j := 0; j < requests; j++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				w := httptest.NewRecorder()
+				generate(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/generate?target=target-%d", i), nil))
+				expected := fmt.Sprintf(`{"target-%d.jinja":"target-%d"}`, i, i)
+				if w.Code != http.StatusOK || w.Body.String() != expected {
+					errs <- fmt.Errorf("expected '%s' for target-%d but got %d: %s", expected, i, w.Code, w.Body.String())
+				}
+			}(i)
+			go func(i int) {
+				defer wg.Done()
+				status, body, err := sendRequest(http.MethodPut, fmt.Sprintf("%s/targets/target-%d", s.URL, i), bodies[i])
+				if err != nil {
+					errs <- err
+				} else if status != http.StatusOK {
+					errs <- fmt.Errorf("failed to replace target-%d: %d: %s", i, status, body)
+				}
+			}(i)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// Test that only one of the parallel requests to create the same template
+// succeeds and that the rest are conflicts.
+func TestConcurrentCreateTemplate(t *testing.T) {
+	var (
+		conf     = config.New()
+		requests = 20
+		created  atomic.Int32
+		wg       sync.WaitGroup
+	)
+	conf.Server.Storage = t.TempDir()
+	router := server.New(&conf).NewRouter()
+
+	// a longer template takes longer to check before it is saved
+	contents := strings.Repeat("{{ plugin_name }}\\n", 100)
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			body := fmt.Sprintf(`{"name": "dnsmasq.jinja", "contents": "%s"}`, contents)
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/templates", strings.NewReader(body)))
+			switch w.Code {
+			case http.StatusCreated:
+				created.Add(1)
+			case http.StatusConflict:
+			default:
+				errs <- fmt.Errorf("expected %d or %d but got %d: %s", http.StatusCreated, http.StatusConflict, w.Code, w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if created.Load() != 1 {
+		t.Errorf("expected the template to be created once but it was created %d times", created.Load())
+	}
+}
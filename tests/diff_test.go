@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/OpenCHAMI/configurator/pkg/util"
+)
+
+// Returns the numbered lines from start to end as the contents of a file.
+func numberedLines(start int, end int) string {
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+// Test that unified diffs match the output of `diff -u` without timestamps.
+func TestUnifiedDiff(t *testing.T) {
+	var tests = []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{
+			name:     "empty",
+			old:      "",
+			new:      "",
+			expected: "",
+		},
+		{
+			name:     "identical",
+			old:      "a\nb\n",
+			new:      "a\nb\n",
+			expected: "",
+		},
+		{
+			name:     "insert only",
+			old:      "",
+			new:      "a\nb\n",
+			expected: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:     "delete only",
+			old:      "a\nb\n",
+			new:      "",
+			expected: "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name:     "insert in the middle",
+			old:      "a\nc\n",
+			new:      "a\nb\nc\n",
+			expected: "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n+b\n c\n",
+		},
+		{
+			name:     "changed line",
+			old:      "a\nb\nc\n",
+			new:      "a\nx\nc\n",
+			expected: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			name:     "missing newline at end of file",
+			old:      "a\nb\n",
+			new:      "a\nb",
+			expected: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			name: "hunk context",
+			old:  numberedLines(1, 10),
+			new:  strings.Replace(numberedLines(1, 10), "line 5\n", "line five\n", 1),
+			expected: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n" +
+				" line 2\n line 3\n line 4\n-line 5\n+line five\n line 6\n line 7\n line 8\n",
+		},
+		{
+			name: "separate hunks",
+			old:  numberedLines(1, 20),
+			new:  strings.Replace(strings.Replace(numberedLines(1, 20), "line 2\n", "line two\n", 1), "line 19\n", "line nineteen\n", 1),
+			expected: "--- old\n+++ new\n" +
+				"@@ -1,5 +1,5 @@\n line 1\n-line 2\n+line two\n line 3\n line 4\n line 5\n" +
+				"@@ -16,5 +16,5 @@\n line 16\n line 17\n line 18\n-line 19\n+line nineteen\n line 20\n",
+		},
+		{
+			name: "overlapping context is merged",
+			old:  numberedLines(1, 10),
+			new:  strings.Replace(strings.Replace(numberedLines(1, 10), "line 3\n", "line three\n", 1), "line 8\n", "line eight\n", 1),
+			expected: "--- old\n+++ new\n@@ -1,10 +1,10 @@\n" +
+				" line 1\n line 2\n-line 3\n+line three\n line 4\n line 5\n line 6\n line 7\n-line 8\n+line eight\n line 9\n line 10\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff := util.UnifiedDiff("old", "new", []byte(test.old), []byte(test.new))
+			if diff != test.expected {
+				t.Errorf("expected diff:\n%s\nbut got:\n%s", test.expected, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+)
+
+// Test that the network filters are available in templates and return the
+// expected values.
+func TestTemplateFilters(t *testing.T) {
+	var tests = []struct {
+		template string
+		expected string
+	}{
+		{"{{ '172.16.0.5/24' | ip_network }}", "172.16.0.0/24"},
+		{"{{ 'fd00::1:5/64' | ip_network }}", "fd00::/64"},
+		{"{{ '172.16.0.5/24' | ip_netmask }}", "255.255.255.0"},
+		{"{{ '10.0.0.0/13' | ip_netmask }}", "255.248.0.0"},
+		{"{{ 'fd00::/48' | ip_netmask }}", "ffff:ffff:ffff::"},
+		{"{{ '172.16.0.5/24' | ip_prefix }}", "24"},
+		{"{{ '172.16.0.5' | ip_reverse }}", "5.0.16.172.in-addr.arpa"},
+		{"{{ '172.16.0.0/16' | ip_reverse }}", "16.172.in-addr.arpa"},
+		{"{{ 'fd00::/16' | ip_reverse }}", "0.0.d.f.ip6.arpa"},
+		{"{{ '2001:db8::1' | ip_reverse }}", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"},
+		{"{{ '172.16.0.0/24' | ip_nth(10) }}", "172.16.0.10"},
+		{"{{ '172.16.0.0/24' | ip_nth(-2) }}", "172.16.0.254"},
+		{"{{ '172.16.0.0/23' | ip_nth(256) }}", "172.16.1.0"},
+		{"{{ 'fd00::/64' | ip_nth(255) }}", "fd00::ff"},
+		{"{{ 'A4:BF:01:38:EE:66' | mac_format }}", "a4:bf:01:38:ee:66"},
+		{"{{ 'a4bf0138ee66' | mac_format('-') }}", "a4-bf-01-38-ee-66"},
+		{"{{ 'a4-bf-01-38-ee-66' | mac_format(separator='.') }}", "a4bf.0138.ee66"},
+		{"{{ 'a4:bf:01:38:ee:66' | mac_to_pxelinux }}", "01-a4-bf-01-38-ee-66"},
+		{"{{ 'x1000c0s7b0n1' | xname_type }}", "Node"},
+		{"{{ 'x1000c0s7b0n1' | xname_parent }}", "x1000c0s7b0"},
+		{"{{ 'x1000c0s7b0n1' | xname_cabinet }}", "x1000"},
+		{"{{ 'x1000c0s7b0n1' | xname_chassis }}", "x1000c0"},
+		{"{{ 'x1000c0s7b0n1' | xname_slot }}", "x1000c0s7"},
+		{"{{ 'x1000c0s7b0n1' | xname_bmc }}", "x1000c0s7b0"},
+		{"{{ ['x1000c0s10b0', 'x1000c0s2b0n0', 'x1000c0s2b0'] | xname_sort | join(' ') }}", "x1000c0s2b0 x1000c0s2b0n0 x1000c0s10b0"},
+		{"{% for c in [{'ID': 'x1c0s10b0'}, {'ID': 'x1c0s9b0'}] | xname_sort(attribute='ID') %}{{ c.ID }} {% endfor %}", "x1c0s9b0 x1c0s10b0 "},
+	}
+	for _, test := range tests {
+		outputs, err := generator.ApplyTemplates(generator.Mappings{}, map[string]generator.Template{
+			"test": {Contents: []byte(test.template)},
+		})
+		if err != nil {
+			t.Errorf("failed to apply template '%s': %v", test.template, err)
+			continue
+		}
+		if string(outputs["test"]) != test.expected {
+			t.Errorf("expected '%s' to render '%s' but got '%s'", test.template, test.expected, string(outputs["test"]))
+		}
+	}
+}
+
+// Test that the network filters return an error for invalid values instead
+// of rendering something unexpected.
+func TestTemplateFiltersWithInvalidValues(t *testing.T) {
+	var templates = []string{
+		"{{ '172.16.0.5' | ip_network }}",
+		"{{ '172.16.0.5/33' | ip_netmask }}",
+		"{{ 'not an ip' | ip_reverse }}",
+		"{{ '172.16.0.0/24' | ip_nth(256) }}",
+		"{{ '172.16.0.0/24' | ip_nth(-257) }}",
+		"{{ '172.16.0.0/24' | ip_nth('a') }}",
+		"{{ 'a4:bf:01:38:ee' | mac_format }}",
+		"{{ 'not a mac' | mac_to_pxelinux }}",
+		"{{ 'x1000' | xname_parent }}",
+		"{{ 'x1000c0s7' | xname_bmc }}",
+		"{{ 'node01' | xname_cabinet }}",
+		"{{ 'x1000' | xname_sort }}",
+	}
+	for _, template := range templates {
+		_, err := generator.ApplyTemplates(generator.Mappings{}, map[string]generator.Template{
+			"test": {Contents: []byte(template)},
+		})
+		if err == nil {
+			t.Errorf("expected an error applying template '%s'", template)
+		}
+	}
+}
@@ -146,11 +146,13 @@ var Generator TestGenerator`)
 		t.Fatalf("expected file but found directory")
 	}
 
-	// change to testing directory to run command
+	// change to testing directory to run command and change back after so
+	// that other tests are ran from the working directory
 	err = os.Chdir(testPluginDir)
 	if err != nil {
 		t.Fatalf("failed to 'cd' to temporary directory: %v", err)
 	}
+	defer os.Chdir(workDir)
 
 	// initialize the plugin directory as a Go project
 	cmd := exec.Command("bash", "-c", "go mod init github.com/OpenCHAMI/configurator-test-plugin")
@@ -276,11 +278,13 @@ var Generator InvalidGenerator
 		t.Fatalf("expected file but found directory")
 	}
 
-	// change to testing directory to run command
+	// change to testing directory to run command and change back after so
+	// that other tests are ran from the working directory
 	err = os.Chdir(testPluginDir)
 	if err != nil {
 		t.Fatalf("failed to 'cd' to temporary directory: %v", err)
 	}
+	defer os.Chdir(workDir)
 
 	// initialize the plugin directory as a Go project
 	cmd := exec.Command("bash", "-c", "go mod init github.com/OpenCHAMI/configurator-test-plugin")
@@ -385,6 +389,7 @@ func TestGenerateExampleWithServer(t *testing.T) {
 	// create new server, add test generator, and start in background
 	server := server.New(&conf)
 	generator.DefaultGenerators["test"] = &gen
+	defer delete(generator.DefaultGenerators, "test")
 	go server.Serve()
 
 	// make request to server to generate a file
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/OpenCHAMI/configurator/pkg/client/smdtest"
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+	"github.com/OpenCHAMI/configurator/pkg/generator/generatortest"
+)
+
+// Test that the output of every built-in generator matches the golden files
+// in "testdata/golden". Run with the "-update" flag to regenerate them:
+//
+//	go test ./tests --tags=all -run TestGolden -update
+func TestGoldenDefaultGenerators(t *testing.T) {
+	var (
+		templateDir = filepath.Join("..", "examples", "templates")
+		goldenDir   = filepath.Join("testdata", "golden")
+		templates   = map[string][]string{
+			"conman":     {"conman.jinja"},
+			"dhcpd":      {"dhcpd.jinja"},
+			"dnsmasq":    {"dnsmasq.jinja"},
+			"bootparams": {"ipxe.jinja", "grub.jinja"},
+		}
+		vars = map[string]map[string]any{
+			"conman": {"server_opts": "timestamp=1h"},
+			"dhcpd":  {"subnet": "172.16.0.0/24", "boot_server": "172.16.0.254"},
+		}
+		names = []string{}
+		cases = []generatortest.Case{}
+	)
+
+	for name := range generator.DefaultGenerators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// only run the built-in generators (other tests add to the defaults)
+		gen := generator.DefaultGenerators[name]
+		if gen.GetName() == "test" {
+			continue
+		}
+
+		// use the example template for the generator if there is one
+		paths := []string{}
+		for _, template := range templates[name] {
+			paths = append(paths, filepath.Join(templateDir, template))
+		}
+		if len(paths) == 0 {
+			paths = append(paths, filepath.Join(templateDir, "test.j2"))
+		}
+
+		cases = append(cases, generatortest.Case{
+			Name:      name,
+			Generator: gen,
+			Templates: generatortest.LoadTemplates(t, paths...),
+			Fixtures:  smdtest.DefaultFixtures(),
+			Vars:      vars[name],
+		})
+	}
+
+	generatortest.Run(t, goldenDir, cases...)
+}
@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/client/smdtest"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+)
+
+// Test that Go templates are rendered with the same mappings as Jinja
+// templates along with the Sprig functions and the network and xname filters
+// as functions.
+func TestApplyGoTemplates(t *testing.T) {
+	var (
+		mappings = generator.Mappings{
+			"subnet":     "172.16.0.0/24",
+			"mac":        "A4BF0138EE66",
+			"name":       "node",
+			"components": []configurator.Component{{ID: "x1000c0s7b1n0"}, {ID: "x1000c0s7b0n1"}},
+		}
+		tests = []struct {
+			template string
+			expected string
+		}{
+			{"{{ .subnet | ip_nth 10 }} {{ ip_netmask .subnet }}", "172.16.0.10 255.255.255.0"},
+			{"{{ .mac | mac_format }} {{ .mac | mac_format \"-\" }}", "a4:bf:01:38:ee:66 a4-bf-01-38-ee-66"},
+			{"{{ .name | upper }} {{ list 1 2 3 | len }}", "NODE 3"},
+			{"{{ range xname_sort \"ID\" .components }}{{ .ID | xname_bmc }} {{ end }}", "x1000c0s7b0 x1000c0s7b1 "},
+			{"{{ .missing }}", "<no value>"},
+		}
+	)
+	for _, test := range tests {
+		outputs, err := generator.ApplyTemplates(mappings, map[string]generator.Template{
+			"test": {Contents: []byte(test.template), Engine: generator.EngineGoTemplate},
+		})
+		if err != nil {
+			t.Errorf("failed to apply template '%s': %v", test.template, err)
+			continue
+		}
+		if string(outputs["test"]) != test.expected {
+			t.Errorf("expected '%s' to render '%s' but got '%s'", test.template, test.expected, string(outputs["test"]))
+		}
+	}
+
+	// errors are reported the same way as Jinja templates
+	var errorTests = []struct {
+		template string
+		strict   bool
+		expected string
+	}{
+		{"{{ .missing }}", true, "failed to execute"},
+		{"\n{{ .name | nope }}", false, "failed to read template from file: template: root:2"},
+		{"{{ env \"HOME\" }}", false, "\"env\" not defined"},
+	}
+	for _, test := range errorTests {
+		_, err := generator.ApplyTemplates(mappings, map[string]generator.Template{
+			"test": {Contents: []byte(test.template), Strict: test.strict, Engine: generator.EngineGoTemplate},
+		})
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected '%s' to return an error with '%s' but got: %v", test.template, test.expected, err)
+		}
+	}
+
+	// unknown engines are an error instead of being rendered with Jinja
+	_, err := generator.ApplyTemplates(mappings, map[string]generator.Template{
+		"test": {Contents: []byte("{{ name }}"), Engine: "mustache"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "mustache") {
+		t.Errorf("expected an error for the unknown engine but got: %v", err)
+	}
+}
+
+// Test that the engine is detected by the template's extension or set for
+// the target in the config and that Go templates find other templates the
+// same way as Jinja templates.
+func TestGoTemplateEngine(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		files = map[string]string{
+			"dnsmasq.tmpl":   "{{ template \"header.tmpl\" . }}{{ .dhcp_hosts }}",
+			"header.tmpl":    "# {{ .plugin_name }}\n",
+			"dnsmasq.jinja":  "{{ plugin_name }}",
+			"dnsmasq.conf.j": "{{ .plugin_name }}",
+		}
+		conf = config.New()
+		s    = smdtest.NewServer(smdtest.DefaultFixtures())
+	)
+	defer s.Close()
+
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatalf("failed to write template: %v", err)
+		}
+	}
+	conf.SmdClient.Host = s.URL
+	conf.Targets["dnsmasq"] = configurator.Target{
+		TemplatePaths: []string{filepath.Join(dir, "dnsmasq.tmpl"), filepath.Join(dir, "dnsmasq.jinja")},
+	}
+
+	outputs, err := generator.GenerateWithTarget(&conf, "dnsmasq")
+	if err != nil {
+		t.Fatalf("failed to generate with target: %v", err)
+	}
+	if output := string(outputs[filepath.Join(dir, "dnsmasq.tmpl")]); !strings.HasPrefix(output, "# dnsmasq\n") {
+		t.Errorf("expected the Go template to include the header but got '%s'", output)
+	}
+	if output := string(outputs[filepath.Join(dir, "dnsmasq.jinja")]); output != "dnsmasq" {
+		t.Errorf("expected the Jinja template to render 'dnsmasq' but got '%s'", output)
+	}
+
+	// Go templates are linted for the variables they use including the
+	// templates they use with "template"
+	_, results, err := generator.LintTarget(&conf, "dnsmasq")
+	if err != nil {
+		t.Fatalf("failed to lint target: %v", err)
+	}
+	for _, result := range results {
+		if filepath.Base(result.Path) != "dnsmasq.tmpl" {
+			continue
+		}
+		if result.Err != nil || !slices.Equal(result.Uses, []string{"dhcp_hosts", "plugin_name"}) {
+			t.Errorf("expected the Go template to use 'dhcp_hosts' and 'plugin_name' but got %v (%v)", result.Uses, result.Err)
+		}
+	}
+
+	// the engine set in the config is used instead of the extension
+	conf.Targets["dnsmasq"] = configurator.Target{
+		TemplatePaths: []string{filepath.Join(dir, "dnsmasq.conf.j")},
+		Engine:        "gotmpl",
+	}
+	outputs, err = generator.GenerateWithTarget(&conf, "dnsmasq")
+	if err != nil {
+		t.Fatalf("failed to generate with target: %v", err)
+	}
+	if output := string(outputs[filepath.Join(dir, "dnsmasq.conf.j")]); output != "dnsmasq" {
+		t.Errorf("expected the template to be rendered with the engine from the config but got '%s'", output)
+	}
+}
+
+// Test that only the variables from the top level of a Go template are found
+// since "." is each item in "range" and the value in "with".
+func TestGoTemplateVariables(t *testing.T) {
+	var tests = []struct {
+		template string
+		expected []string
+	}{
+		{"{{ .dhcp_hosts }}", []string{"dhcp_hosts"}},
+		{"{{ range .nodes }}{{ .ID }} {{ $.domain }}{{ else }}{{ .empty }}{{ end }}", []string{"domain", "empty", "nodes"}},
+		{"{{ with .smd }}{{ .components }}{{ end }}{{ if .subnet }}{{ ip_nth 1 .subnet }}{{ end }}", []string{"smd", "subnet"}},
+		{"{{ define \"host\" }}{{ .name }}{{ end }}{{ template \"host\" . }}", []string{"name"}},
+	}
+	for _, test := range tests {
+		names, err := generator.TemplateVariables(generator.Template{Contents: []byte(test.template), Engine: generator.EngineGoTemplate})
+		if err != nil {
+			t.Errorf("failed to get variables for '%s': %v", test.template, err)
+			continue
+		}
+		if !slices.Equal(names, test.expected) {
+			t.Errorf("expected '%s' to use %v but got %v", test.template, test.expected, names)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+)
+
+func graphConfig() config.Config {
+	conf := config.New()
+	conf.Targets = map[string]configurator.Target{
+		"coredhcp": {RunTargets: []string{"dnsmasq", "conman"}},
+		"dnsmasq":  {RunTargets: []string{"hosts", "dnsmasq"}},
+		"conman":   {RunTargets: []string{"hosts"}},
+		"a":        {RunTargets: []string{"b"}},
+		"b":        {RunTargets: []string{"c"}},
+		"c":        {RunTargets: []string{"a"}},
+	}
+	return conf
+}
+
+// Test that targets are resolved in order with shared targets only included
+// once and that cycles are found.
+func TestResolveTargets(t *testing.T) {
+	conf := graphConfig()
+
+	graph, err := generator.ResolveTargets(&conf, "coredhcp")
+	if err != nil {
+		t.Fatalf("failed to resolve targets: %v", err)
+	}
+	expected := []string{"coredhcp", "dnsmasq", "conman", "hosts"}
+	if !slices.Equal(graph.Targets, expected) {
+		t.Errorf("expected targets %v but got %v", expected, graph.Targets)
+	}
+	if dot := graph.DOT(); !strings.Contains(dot, `"conman" -> "hosts";`) {
+		t.Errorf("expected edge from 'conman' to 'hosts' in graph:\n%s", dot)
+	}
+
+	_, err = generator.ResolveTargets(&conf, "a")
+	if err == nil || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Errorf("expected an error with the cycle but got: %v", err)
+	}
+}
+
+// Test that independent targets run in parallel after the targets that run
+// them and that targets are skipped after a failure.
+func TestRunTargetGraph(t *testing.T) {
+	var (
+		conf     = graphConfig()
+		mu       sync.Mutex
+		finished = map[string]bool{}
+		running  = 0
+		overlap  = false
+	)
+	graph, err := generator.ResolveTargets(&conf, "coredhcp")
+	if err != nil {
+		t.Fatalf("failed to resolve targets: %v", err)
+	}
+
+	err = graph.Run(context.Background(), func(ctx context.Context, target string) error {
+		mu.Lock()
+		for _, parent := range []string{"coredhcp", "dnsmasq", "conman"} {
+			if parent != target && slices.Contains(conf.Targets[parent].RunTargets, target) && !finished[parent] {
+				t.Errorf("'%s' ran before '%s' finished", target, parent)
+			}
+		}
+		running++
+		overlap = overlap || running > 1
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		finished[target] = true
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to run targets: %v", err)
+	}
+	if len(finished) != 4 {
+		t.Errorf("expected 4 targets to run but got %d", len(finished))
+	}
+	if !overlap {
+		t.Error("expected 'dnsmasq' and 'conman' to run in parallel")
+	}
+
+	// targets ran by a failed target should be skipped
+	ran := []string{}
+	err = graph.Run(context.Background(), func(ctx context.Context, target string) error {
+		mu.Lock()
+		ran = append(ran, target)
+		mu.Unlock()
+		if target == "coredhcp" {
+			return errors.New("failed to generate")
+		}
+		return nil
+	})
+	if err == nil || len(ran) != 1 {
+		t.Errorf("expected only 'coredhcp' to run and fail but ran %v (%v)", ran, err)
+	}
+}
@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/client/smdtest"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+)
+
+// Test that undefined variables are an error in strict templates and render
+// as empty values otherwise.
+func TestStrictTemplates(t *testing.T) {
+	mappings := generator.Mappings{"dhcp_hosts": "dhcp-host=a4:bf:01:38:ee:66"}
+
+	outputs, err := generator.ApplyTemplates(mappings, map[string]generator.Template{
+		"test": {Contents: []byte("{{ dhcp_host }}")},
+	})
+	if err != nil || string(outputs["test"]) != "" {
+		t.Errorf("expected the undefined variable to render empty but got '%s' (%v)", string(outputs["test"]), err)
+	}
+
+	_, err = generator.ApplyTemplates(mappings, map[string]generator.Template{
+		"test": {Contents: []byte("{{ dhcp_host }}"), Strict: true},
+	})
+	if err == nil || !strings.Contains(err.Error(), "dhcp_host") {
+		t.Errorf("expected an error for the undefined variable but got: %v", err)
+	}
+
+	outputs, err = generator.ApplyTemplates(mappings, map[string]generator.Template{
+		"test": {Contents: []byte("{{ dhcp_hosts }}"), Strict: true},
+	})
+	if err != nil || string(outputs["test"]) != mappings["dhcp_hosts"] {
+		t.Errorf("expected '%s' but got '%s' (%v)", mappings["dhcp_hosts"], string(outputs["test"]), err)
+	}
+}
+
+// Test that templates with tags that are never closed return an error with
+// where the tag starts instead of never finishing.
+func TestUnclosedTemplateTags(t *testing.T) {
+	var tests = []struct {
+		template string
+		expected string
+	}{
+		{"{{ dhcp_hosts ", "unclosed '{{' at line 1 column 1"},
+		{"{{ a }}\n  {% if a ", "unclosed '{%' at line 2 column 3"},
+		{"{% raw %}{{ a {% endraw %}{{ b ", "unclosed '{{' at line 1 column 27"},
+	}
+	for _, test := range tests {
+		_, err := generator.ApplyTemplates(generator.Mappings{}, map[string]generator.Template{
+			"test": {Contents: []byte(test.template)},
+		})
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected '%s' to return an error with '%s' but got: %v", test.template, test.expected, err)
+		}
+	}
+
+	// tags in raw blocks and comments don't need to be closed
+	for _, template := range []string{"{% raw %}{{ a {% endraw %}", "{# {{ a #}"} {
+		if _, err := generator.ApplyTemplates(generator.Mappings{}, map[string]generator.Template{
+			"test": {Contents: []byte(template)},
+		}); err != nil {
+			t.Errorf("failed to apply template '%s': %v", template, err)
+		}
+	}
+}
+
+// Test that the variables used by a template are found without the names
+// that are set in the template or are not variables.
+func TestTemplateVariables(t *testing.T) {
+	var tests = []struct {
+		template string
+		expected []string
+	}{
+		{"{{ dhcp_hosts }}", []string{"dhcp_hosts"}},
+		{"{{ node.ID | lower }} {{ node['Role'] }}", []string{"node"}},
+		{"{% for c in components if c.Role == role %}{{ loop.index }} {{ c.ID }}{% endfor %}", []string{"components", "role"}},
+		{"{% set domain = cluster ~ '.local' %}{{ domain }}", []string{"cluster"}},
+		{"{% if nodes is defined and nodes is not none %}{{ nodes | join(sep=separator) }}{% endif %}", []string{"nodes", "separator"}},
+		{"{% macro host(name, ip=default_ip) %}{{ name }} {{ ip }}{% endmacro %}{{ host('x1000') }}", []string{"default_ip"}},
+		{"{% with count = nodes | length %}{{ count }}{% endwith %}{% for i in range(count) %}{% endfor %}", []string{"nodes"}},
+		{"{% block body %}{% filter upper %}{{ name }}{% endfilter %}{% endblock %}", []string{"name"}},
+		{"{% raw %}{{ not_a_variable }}{% endraw %}", []string{}},
+	}
+	for _, test := range tests {
+		names, err := generator.TemplateVariables(generator.Template{Contents: []byte(test.template)})
+		if err != nil {
+			t.Errorf("failed to get variables for '%s': %v", test.template, err)
+			continue
+		}
+		if !slices.Equal(names, test.expected) {
+			t.Errorf("expected '%s' to use %v but got %v", test.template, test.expected, names)
+		}
+	}
+
+	// syntax errors are returned with where they are
+	_, err := generator.TemplateVariables(generator.Template{Contents: []byte("\n{% for n in nodes %}")})
+	if err == nil || !strings.Contains(err.Error(), "Line: 2") {
+		t.Errorf("expected an error with the line of the unclosed 'for' but got: %v", err)
+	}
+}
+
+// Test linting a target's templates for syntax errors and the variables that
+// the generator doesn't provide.
+func TestLintTarget(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		files = map[string]string{
+			"good.jinja":   "{% include 'header.jinja' %}{{ dhcp_hosts }}",
+			"header.jinja": "# {{ plugin_name }} {{ cluster_name }}",
+			"typo.jinja":   "{{ dhcp_host }}",
+			"syntax.jinja": "{{ dhcp_hosts | }}",
+			"item.jinja":   "{{ item.ID }} {{ MacAddress }}",
+		}
+		conf  = config.New()
+		paths = []string{}
+	)
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatalf("failed to write template: %v", err)
+		}
+		if name != "header.jinja" {
+			paths = append(paths, path)
+		}
+	}
+	conf.Targets["dnsmasq"] = configurator.Target{
+		TemplatePaths: paths,
+		Outputs: map[string]configurator.Output{
+			filepath.Join(dir, "item.jinja"): {Path: "{{ item.ID }}", ForEach: "interfaces"},
+		},
+	}
+
+	provides, results, err := generator.LintTarget(&conf, "dnsmasq")
+	if err != nil {
+		t.Fatalf("failed to lint target: %v", err)
+	}
+	if !slices.Contains(provides, "dhcp_hosts") {
+		t.Errorf("expected dnsmasq to provide 'dhcp_hosts' but got %v", provides)
+	}
+	for _, result := range results {
+		switch filepath.Base(result.Path) {
+		case "good.jinja":
+			if result.Err != nil || !slices.Equal(result.Undefined, []string{"cluster_name"}) {
+				t.Errorf("expected 'cluster_name' from the included template to be undefined but got %v (%v)", result.Undefined, result.Err)
+			}
+		case "typo.jinja":
+			if result.Err != nil || !slices.Equal(result.Undefined, []string{"dhcp_host"}) {
+				t.Errorf("expected 'dhcp_host' to be undefined but got %v (%v)", result.Undefined, result.Err)
+			}
+		case "syntax.jinja":
+			if result.Err == nil || !strings.Contains(result.Err.Error(), "Line: 1 Col: 17") {
+				t.Errorf("expected a syntax error with the line and column but got: %v", result.Err)
+			}
+		case "item.jinja":
+			if result.ForEach != "interfaces" || !slices.Equal(result.Undefined, []string{"MacAddress"}) {
+				t.Errorf("expected only the item field to be undefined but got %v", result.Undefined)
+			}
+		default:
+			t.Errorf("unexpected result for '%s'", result.Path)
+		}
+	}
+	if len(results) != len(paths) {
+		t.Errorf("expected %d results but got %d", len(paths), len(results))
+	}
+
+	// templates that fail to load are an error instead of being skipped
+	conf.Targets["dnsmasq"] = configurator.Target{TemplatePaths: []string{filepath.Join(dir, "missing.jinja")}}
+	if _, _, err := generator.LintTarget(&conf, "dnsmasq"); err == nil {
+		t.Error("expected an error linting a target with a missing template")
+	}
+	if _, err := generator.GenerateWithTarget(&conf, "dnsmasq"); err == nil || !strings.Contains(err.Error(), "missing.jinja") {
+		t.Errorf("expected an error generating a target with a missing template but got: %v", err)
+	}
+}
+
+// Test that every variable the built-in generators list is passed to their
+// templates by rendering a strict template that uses all of them.
+func TestGeneratorVariables(t *testing.T) {
+	var (
+		conf = config.New()
+		s    = smdtest.NewServer(smdtest.DefaultFixtures())
+	)
+	defer s.Close()
+
+	for name, gen := range generator.DefaultGenerators {
+		lister, ok := gen.(generator.VariableLister)
+		if !ok {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			variables := lister.GetVariables()
+			params := fakeSmdParams(s, fmt.Sprintf("{{ [%s] | length }}", strings.Join(variables, ", ")))
+			for path, template := range params.Templates {
+				template.Strict = true
+				params.Templates[path] = template
+			}
+			fileMap, err := gen.Generate(&conf, params)
+			if err != nil {
+				t.Fatalf("failed to generate file with variables %v: %v", variables, err)
+			}
+			if string(fileMap["test"]) != fmt.Sprint(len(variables)) {
+				t.Errorf("expected %d variables but got '%s'", len(variables), string(fileMap["test"]))
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+)
+
+// Test that templates can include, extend, and import other templates found
+// next to them or in the search path.
+func TestTemplateSearchPath(t *testing.T) {
+	var (
+		dir       = t.TempDir()
+		targetDir = filepath.Join(dir, "target")
+		globalDir = filepath.Join(dir, "global")
+		files     = map[string]string{
+			"templates/dnsmasq.jinja": "{% include 'sibling.jinja' %}|{% include 'header.jinja' %}",
+			"templates/sibling.jinja": "sibling",
+			"target/header.jinja":     "target header",
+			"global/header.jinja":     "global header",
+			"global/base.jinja":       "<{% block body %}{% endblock %}>",
+			"global/macros.jinja":     "{% macro host(name) %}host {{ name }}{% endmacro %}",
+		}
+	)
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to make directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatalf("failed to write template: %v", err)
+		}
+	}
+
+	// the target's directories are searched before the global ones
+	conf := config.New()
+	conf.TemplateDirs = []string{globalDir}
+	conf.Targets["dnsmasq"] = configurator.Target{TemplateDirs: []string{targetDir}}
+	searchPath := conf.TemplateSearchPath("dnsmasq")
+	if !slices.Equal(searchPath, []string{targetDir, globalDir}) {
+		t.Fatalf("expected search path %v but got %v", []string{targetDir, globalDir}, searchPath)
+	}
+
+	template := generator.Template{SearchPath: searchPath}
+	if err := template.LoadFromFile(filepath.Join(dir, "templates", "dnsmasq.jinja")); err != nil {
+		t.Fatalf("failed to load template: %v", err)
+	}
+	tests := []struct {
+		name     string
+		template generator.Template
+		expected string
+	}{
+		{"include", template, "sibling|target header"},
+		{"global", generator.Template{Contents: []byte("{% include 'header.jinja' %}"), SearchPath: []string{globalDir}}, "global header"},
+		{"extends", generator.Template{Contents: []byte("{% extends 'base.jinja' %}{% block body %}body{% endblock %}"), SearchPath: searchPath}, "<body>"},
+		{"import", generator.Template{Contents: []byte("{% import 'macros.jinja' as m %}{{ m.host('x1000c0s0b0n0') }}"), SearchPath: searchPath}, "host x1000c0s0b0n0"},
+	}
+	for _, test := range tests {
+		outputs, err := generator.ApplyTemplates(generator.Mappings{}, map[string]generator.Template{"test": test.template})
+		if err != nil {
+			t.Errorf("%s: failed to apply template: %v", test.name, err)
+			continue
+		}
+		if strings.TrimSpace(string(outputs["test"])) != test.expected {
+			t.Errorf("%s: expected '%s' but got '%s'", test.name, test.expected, string(outputs["test"]))
+		}
+	}
+
+	// templates that can't be found should return an error
+	_, err := generator.ApplyTemplates(generator.Mappings{}, map[string]generator.Template{
+		"test": {Contents: []byte("{% include 'missing.jinja' %}"), SearchPath: searchPath},
+	})
+	if err == nil || !strings.Contains(err.Error(), "missing.jinja") {
+		t.Errorf("expected an error for the missing template but got: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+	"gopkg.in/yaml.v2"
+)
+
+// Test that templates with an output set are keyed by the rendered output
+// path and rendered once for each item with "foreach".
+func TestApplyTemplatesWithOutputs(t *testing.T) {
+	var (
+		mappings = generator.Mappings{
+			"domain": "openchami.cluster",
+			"nodes": []map[string]any{
+				{"xname": "x1000c0s0b0n0", "ip": "172.16.0.1"},
+				{"xname": "x1000c0s1b0n0", "ip": "172.16.0.2"},
+			},
+		}
+		templates = map[string]generator.Template{
+			"templates/a/hosts.j2": {
+				Contents: []byte("{{ ip }} {{ xname }}.{{ domain }}"),
+				Output:   &configurator.Output{Path: "{{ xname }}/hosts", ForEach: "nodes"},
+			},
+			"templates/b/hosts.j2": {
+				Contents: []byte("{{ domain }}"),
+				Output:   &configurator.Output{Path: "/etc/hosts"},
+			},
+			"templates/dnsmasq.j2": {
+				Contents: []byte("{{ domain }}"),
+			},
+		}
+		expected = map[string]string{
+			"x1000c0s0b0n0/hosts":  "172.16.0.1 x1000c0s0b0n0.openchami.cluster",
+			"x1000c0s1b0n0/hosts":  "172.16.0.2 x1000c0s1b0n0.openchami.cluster",
+			"etc/hosts":            "openchami.cluster",
+			"templates/dnsmasq.j2": "openchami.cluster",
+		}
+	)
+
+	outputs, err := generator.ApplyTemplates(mappings, templates)
+	if err != nil {
+		t.Fatalf("failed to apply templates: %v", err)
+	}
+	if len(outputs) != len(expected) {
+		t.Errorf("expected %d outputs but got %d: %v", len(expected), len(outputs), generator.ConvertContentsToString(outputs))
+	}
+	for path, contents := range expected {
+		if string(outputs[path]) != contents {
+			t.Errorf("expected '%s' to contain '%s' but got '%s'", path, contents, string(outputs[path]))
+		}
+	}
+
+	// templates writing to the same path or outside the output path are errors
+	for _, output := range []configurator.Output{
+		{Path: "hosts", ForEach: "missing"},
+		{Path: "../hosts"},
+		{Path: "hosts", ForEach: "nodes"},
+	} {
+		templates := map[string]generator.Template{"hosts.j2": {Output: &output}}
+		if _, err := generator.ApplyTemplates(mappings, templates); err == nil {
+			t.Errorf("expected an error for output %+v", output)
+		}
+	}
+}
+
+// Test that outputs can be set in the config with just a path or with a path
+// and "foreach".
+func TestTargetOutputsFromConfig(t *testing.T) {
+	var (
+		target configurator.Target
+		data   = strings.Join([]string{
+			"outputs:",
+			"  templates/dnsmasq.j2: dnsmasq.d/openchami.conf",
+			"  templates/hosts.j2:",
+			"    path: '{{ xname }}/hosts'",
+			"    foreach: nodes",
+		}, "\n")
+	)
+	if err := yaml.Unmarshal([]byte(data), &target); err != nil {
+		t.Fatalf("failed to unmarshal target: %v", err)
+	}
+	if output := target.Outputs["templates/dnsmasq.j2"]; output.Path != "dnsmasq.d/openchami.conf" || output.ForEach != "" {
+		t.Errorf("unexpected output for path only: %+v", output)
+	}
+	if output := target.Outputs["templates/hosts.j2"]; output.Path != "{{ xname }}/hosts" || output.ForEach != "nodes" {
+		t.Errorf("unexpected output for path and foreach: %+v", output)
+	}
+}
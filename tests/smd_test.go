@@ -0,0 +1,245 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/configurator/pkg/client"
+	"github.com/OpenCHAMI/configurator/pkg/client/smdtest"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+)
+
+// Returns the generator params with client options that point to the fake
+// SMD service with retries that don't take long to run.
+func fakeSmdParams(s *smdtest.Server, template string, opts ...client.Option) generator.Params {
+	opts = append([]client.Option{
+		client.WithHost(s.URL),
+		client.WithRetries(2, time.Millisecond),
+	}, opts...)
+	return generator.Params{
+		Templates:     map[string]generator.Template{"test": {Contents: []byte(template)}},
+		ClientOpts:    opts,
+		BssClientOpts: opts,
+	}
+}
+
+// Test that each of the built-in generators that fetch data from SMD can
+// generate files using the fake SMD service.
+func TestGenerateWithFakeSmd(t *testing.T) {
+	var (
+		conf  = config.New()
+		s     = smdtest.NewServer(smdtest.DefaultFixtures())
+		tests = []struct {
+			generator string
+			template  string
+			expected  string
+		}{
+			{"dnsmasq", "{{ dhcp_hosts }}", "dhcp-host=a4:bf:01:38:ee:66,x1000c0s0b0n0,172.16.0.1"},
+			{"conman", "{{ consoles }}", "CONSOLE name=x1000c0s1b0 dev=ipmi:x1000c0s1b0-bmc"},
+			{"dhcpd", "{{ compute_nodes }}", "host x1000c0s0b0n0 { hardware ethernet a4:bf:01:38:ee:66;"},
+			{"warewulf", "{{ node_entries }}{% for iface in interfaces %}{{ iface.ComponentId }} {{ iface.MacAddress }}\n{% endfor %}", "x1000c0s0b0n0 a4:bf:01:38:ee:66"},
+			{"bootparams", "{{ ipxe_entries }}", "kernel http://172.16.0.254/boot/vmlinuz console=ttyS0,115200 ip=dhcp"},
+		}
+	)
+	defer s.Close()
+
+	for _, test := range tests {
+		t.Run(test.generator, func(t *testing.T) {
+			gen, ok := generator.DefaultGenerators[test.generator]
+			if !ok {
+				t.Fatalf("generator '%s' not found in default generators", test.generator)
+			}
+			fileMap, err := gen.Generate(&conf, fakeSmdParams(s, test.template))
+			if err != nil {
+				t.Fatalf("failed to generate file: %v", err)
+			}
+			if !strings.Contains(string(fileMap["test"]), test.expected) {
+				t.Errorf("expected output to contain '%s' but got:\n%s", test.expected, string(fileMap["test"]))
+			}
+		})
+	}
+}
+
+// Test that errors from SMD are returned as typed errors and that only server
+// errors are retried.
+func TestFetchWithFakeSmdErrors(t *testing.T) {
+	var (
+		s        = smdtest.NewServer(smdtest.DefaultFixtures())
+		endpoint = "/hsm/v2/Inventory/EthernetInterfaces"
+	)
+	defer s.Close()
+
+	s.SetError(endpoint, http.StatusInternalServerError)
+	smdClient, err := client.NewSmdClient(client.WithHost(s.URL), client.WithRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	_, err = smdClient.FetchEthernetInterfaces(context.Background(), false)
+	if !errors.Is(err, client.ErrServerError) {
+		t.Errorf("expected a server error but got: %v", err)
+	}
+	if s.Requests(endpoint) != 3 {
+		t.Errorf("expected 3 requests with retries but got %d", s.Requests(endpoint))
+	}
+
+	s.SetError(endpoint, http.StatusNotFound)
+	_, err = smdClient.FetchEthernetInterfaces(context.Background(), false)
+	var statusErr *client.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a status error with 404 but got: %v", err)
+	}
+	if !errors.Is(err, client.ErrClientError) {
+		t.Errorf("expected a client error but got: %v", err)
+	}
+	if s.Requests(endpoint) != 4 {
+		t.Errorf("expected client errors to not be retried but got %d requests", s.Requests(endpoint))
+	}
+}
+
+// Test that requests time out when SMD takes too long to respond.
+func TestFetchWithFakeSmdLatency(t *testing.T) {
+	var s = smdtest.NewServer(smdtest.DefaultFixtures())
+	defer s.Close()
+
+	s.SetLatency(time.Second)
+	smdClient, err := client.NewSmdClient(
+		client.WithHost(s.URL),
+		client.WithTimeout(50*time.Millisecond),
+		client.WithRetries(0, 0),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	_, err = smdClient.FetchComponents(context.Background(), false)
+	if err == nil {
+		t.Fatal("expected request to time out")
+	}
+}
+
+// Test that generators sharing a cache only fetch each resource once.
+func TestGenerateWithSharedCache(t *testing.T) {
+	var (
+		conf  = config.New()
+		s     = smdtest.NewServer(smdtest.DefaultFixtures())
+		cache = client.NewCache(0)
+	)
+	defer s.Close()
+
+	for _, name := range []string{"dnsmasq", "dhcpd", "warewulf"} {
+		_, err := generator.DefaultGenerators[name].Generate(&conf, fakeSmdParams(s, "", client.WithCache(cache)))
+		if err != nil {
+			t.Fatalf("failed to generate with '%s': %v", name, err)
+		}
+	}
+	if n := s.Requests("/hsm/v2/Inventory/EthernetInterfaces"); n != 1 {
+		t.Errorf("expected ethernet interfaces to be fetched once but was fetched %d times", n)
+	}
+	if hits, misses := cache.Stats(); hits != 2 || misses != 2 {
+		t.Errorf("expected 2 cache hits and 2 misses but got %d and %d", hits, misses)
+	}
+}
+
+// Test that every built-in generator that fetches data from SMD passes the
+// components, interfaces, and Redfish endpoints to templates as lists.
+func TestGenerateWithInventoryLists(t *testing.T) {
+	var (
+		conf     = config.New()
+		s        = smdtest.NewServer(smdtest.DefaultFixtures())
+		template = "{% for c in components %}{{ c.ID }}:{{ c.Type }}\n{% endfor %}" +
+			"{% for iface in interfaces %}dhcp-host={{ iface.MacAddress }},{{ iface.ComponentId }},{{ iface.IpAddresses[0].IpAddress }}\n{% endfor %}" +
+			"{% for ep in redfish_endpoints %}{{ ep.Name }}@{{ ep.IPAddr }}\n{% endfor %}"
+		expected = []string{
+			"x1000c0s0b0n0:Node",
+			"dhcp-host=a4:bf:01:38:ee:76,x1000c0s1b0n0,172.16.0.2",
+			"x1000c0s1b0@172.16.0.102",
+		}
+	)
+	defer s.Close()
+
+	for _, name := range []string{"dnsmasq", "conman", "dhcpd", "warewulf", "bootparams"} {
+		t.Run(name, func(t *testing.T) {
+			fileMap, err := generator.DefaultGenerators[name].Generate(&conf, fakeSmdParams(s, template))
+			if err != nil {
+				t.Fatalf("failed to generate file: %v", err)
+			}
+			for _, line := range expected {
+				if !strings.Contains(string(fileMap["test"]), line) {
+					t.Errorf("expected output to contain '%s' but got:\n%s", line, string(fileMap["test"]))
+				}
+			}
+		})
+	}
+}
+
+// Test that every built-in generator that fetches data from SMD passes the
+// hardware inventory and endpoints to templates under the "smd" namespace.
+func TestGenerateWithSmdNamespace(t *testing.T) {
+	var (
+		conf     = config.New()
+		s        = smdtest.NewServer(smdtest.DefaultFixtures())
+		template = "{% for hw in smd.hardware %}{{ hw.ID }} cpus={{ hw.NodeLocationInfo.ProcessorSummary.Count }}\n{% endfor %}" +
+			"{% for ep in smd.component_endpoints %}{{ ep.RedfishURL }}\n{% endfor %}"
+		expected = []string{
+			"x1000c0s0b0n0 cpus=2",
+			"x1000c0s1b0/redfish/v1/Systems/Node0",
+		}
+	)
+	defer s.Close()
+
+	for _, name := range []string{"dnsmasq", "conman", "dhcpd", "warewulf", "bootparams"} {
+		t.Run(name, func(t *testing.T) {
+			fileMap, err := generator.DefaultGenerators[name].Generate(&conf, fakeSmdParams(s, template))
+			if err != nil {
+				t.Fatalf("failed to generate file: %v", err)
+			}
+			for _, line := range expected {
+				if !strings.Contains(string(fileMap["test"]), line) {
+					t.Errorf("expected output to contain '%s' but got:\n%s", line, string(fileMap["test"]))
+				}
+			}
+		})
+	}
+}
+
+// Test that generators only fetch the inventory that they or their templates
+// use and that BMC passwords are not passed to templates.
+func TestGenerateOnlyFetchesUsedInventory(t *testing.T) {
+	var (
+		conf = config.New()
+		s    = smdtest.NewServer(smdtest.DefaultFixtures())
+	)
+	defer s.Close()
+
+	// endpoints that dnsmasq's template doesn't use can be unavailable
+	s.SetError("/hsm/v2/State/Components", http.StatusForbidden)
+	s.SetError("/hsm/v2/Inventory/RedfishEndpoints", http.StatusForbidden)
+	_, err := generator.DefaultGenerators["dnsmasq"].Generate(&conf, fakeSmdParams(s, "{{ dhcp_hosts }}"))
+	if err != nil {
+		t.Fatalf("failed to generate file: %v", err)
+	}
+	for _, endpoint := range []string{"/hsm/v2/State/Components", "/hsm/v2/Inventory/RedfishEndpoints", "/hsm/v2/Inventory/Hardware"} {
+		if n := s.Requests(endpoint); n != 0 {
+			t.Errorf("expected '%s' to not be fetched but was fetched %d times", endpoint, n)
+		}
+	}
+
+	// the lists are fetched when templates use them
+	_, err = generator.DefaultGenerators["dnsmasq"].Generate(&conf, fakeSmdParams(s, "{% for c in components %}{{ c.ID }}{% endfor %}"))
+	if err == nil {
+		t.Error("expected an error when the template uses components that can't be fetched")
+	}
+
+	s.SetError("/hsm/v2/Inventory/RedfishEndpoints", 0)
+	fileMap, err := generator.DefaultGenerators["dnsmasq"].Generate(&conf, fakeSmdParams(s, "{% for ep in redfish_endpoints %}{{ ep.User }}:{{ ep.Password }}\n{% endfor %}"))
+	if err != nil {
+		t.Fatalf("failed to generate file: %v", err)
+	}
+	if output := string(fileMap["test"]); strings.Contains(output, "secret") || !strings.Contains(output, "root:") {
+		t.Errorf("expected the Redfish endpoints without passwords but got:\n%s", output)
+	}
+}
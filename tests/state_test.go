@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/state"
+)
+
+// Test that versions are saved with their meta, loaded back, and that only
+// the most recent versions are kept.
+func TestStateSaveAndRollback(t *testing.T) {
+	var (
+		store = state.NewStore(t.TempDir(), 2)
+		path  = "/etc/dhcpd.conf"
+	)
+
+	for _, contents := range []string{"first", "second", "third"} {
+		_, err := store.Save(state.Meta{
+			Target:           "dhcpd",
+			GeneratorVersion: "abcd1234",
+			InventoryHash:    contents,
+		}, map[string][]byte{path: []byte(contents)})
+		if err != nil {
+			t.Fatalf("failed to save version: %v", err)
+		}
+	}
+
+	versions, err := store.List("dhcpd")
+	if err != nil {
+		t.Fatalf("failed to list versions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Version != 3 {
+		t.Fatalf("expected versions 2 and 3 to be kept but got %+v", versions)
+	}
+
+	meta, files, err := store.Load("dhcpd", 2)
+	if err != nil {
+		t.Fatalf("failed to load version: %v", err)
+	}
+	if string(files[path]) != "second" {
+		t.Errorf("expected file to contain 'second' but got '%s'", string(files[path]))
+	}
+	if meta.Target != "dhcpd" || meta.GeneratorVersion != "abcd1234" || meta.InventoryHash != "second" {
+		t.Errorf("unexpected meta for version: %+v", meta)
+	}
+	if meta.Timestamp.IsZero() {
+		t.Error("expected timestamp to be set")
+	}
+
+	if _, _, err = store.Load("dhcpd", 1); err == nil {
+		t.Error("expected an error loading a version that was removed")
+	}
+	if _, err = store.List("../dhcpd"); err == nil {
+		t.Error("expected an error for an invalid target name")
+	}
+}
+
+// Test that hooks only run when the applied files change and that hooks that
+// fail are ran again by the next apply until they succeed.
+func TestStateApplyRunsHooks(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		store  = state.NewStore(filepath.Join(dir, "state"), 0)
+		path   = filepath.Join(dir, "dnsmasq.conf")
+		ran    = filepath.Join(dir, "ran")
+		fail   = filepath.Join(dir, "fail")
+		meta   = state.Meta{Target: "dnsmasq"}
+		target = configurator.Target{
+			// record each time the hooks run and fail while the file exists
+			Hooks: []string{"echo x >> " + ran, "test ! -e " + fail},
+		}
+	)
+
+	// returns the number of times that the hooks ran
+	hookRuns := func() int {
+		b, err := os.ReadFile(ran)
+		if os.IsNotExist(err) {
+			return 0
+		} else if err != nil {
+			t.Fatalf("failed to read hook output: %v", err)
+		}
+		return strings.Count(string(b), "x")
+	}
+	apply := func(contents string, expectChanged bool, expectErr bool, expectRuns int) {
+		t.Helper()
+		changed, err := store.Apply(meta, target, map[string][]byte{path: []byte(contents)})
+		if expectErr && err == nil {
+			t.Errorf("expected the hooks to fail")
+		} else if !expectErr && err != nil {
+			t.Errorf("failed to apply: %v", err)
+		}
+		if changed != expectChanged {
+			t.Errorf("expected changed to be %v", expectChanged)
+		}
+		if runs := hookRuns(); runs != expectRuns {
+			t.Errorf("expected hooks to have ran %d times but ran %d times", expectRuns, runs)
+		}
+	}
+
+	// hooks run when the file is first written but not when it's the same
+	apply("first", true, false, 1)
+	apply("first", false, false, 1)
+
+	// hooks that fail are ran again without changes until they succeed
+	if err := os.WriteFile(fail, []byte{}, 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	apply("second", true, true, 2)
+	if pending, err := store.HooksPending("dnsmasq"); err != nil || !pending {
+		t.Errorf("expected hooks to be pending after failing: %v", err)
+	}
+	apply("second", false, true, 3)
+	if err := os.Remove(fail); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	apply("second", false, false, 4)
+	if pending, err := store.HooksPending("dnsmasq"); err != nil || pending {
+		t.Errorf("expected no hooks to be pending after succeeding: %v", err)
+	}
+	apply("second", false, false, 4)
+
+	// only the applies that changed the file are kept as versions
+	versions, err := store.List("dnsmasq")
+	if err != nil {
+		t.Fatalf("failed to list versions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("expected 2 versions but got %+v", versions)
+	}
+}
+
+// Test that rolling back restores the version applied before the current one
+// and that rolling back again does not return to the version rolled back from.
+func TestStateRollback(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		store  = state.NewStore(filepath.Join(dir, "state"), 0)
+		path   = filepath.Join(dir, "dhcpd.conf")
+		meta   = state.Meta{Target: "dhcpd"}
+		target = configurator.Target{}
+	)
+
+	// checks the contents of the file and the version to roll back to next
+	expect := func(contents string, previous int) {
+		t.Helper()
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read file: %v", err)
+		}
+		if string(b) != contents {
+			t.Errorf("expected file to contain '%s' but got '%s'", contents, string(b))
+		}
+		meta, err := store.Previous("dhcpd")
+		if previous == 0 && err == nil {
+			t.Errorf("expected no version to roll back to but got %d", meta.Version)
+		} else if previous != 0 && (err != nil || meta.Version != previous) {
+			t.Errorf("expected to roll back to version %d but got %d: %v", previous, meta.Version, err)
+		}
+	}
+	rollback := func() {
+		t.Helper()
+		previous, err := store.Previous("dhcpd")
+		if err != nil {
+			t.Fatalf("failed to find version to roll back to: %v", err)
+		}
+		if _, err = store.Restore(target, "dhcpd", previous.Version); err != nil {
+			t.Fatalf("failed to roll back: %v", err)
+		}
+	}
+
+	for _, contents := range []string{"good", "bad"} {
+		if _, err := store.Apply(meta, target, map[string][]byte{path: []byte(contents)}); err != nil {
+			t.Fatalf("failed to apply: %v", err)
+		}
+	}
+	expect("bad", 1)
+
+	// version 3 restores version 1 and there is nothing before it
+	rollback()
+	expect("good", 0)
+
+	// version 4 is applied after the rollback, so rolling back restores the
+	// good files from version 3 and then nothing before version 1
+	if _, err := store.Apply(meta, target, map[string][]byte{path: []byte("new")}); err != nil {
+		t.Fatalf("failed to apply: %v", err)
+	}
+	expect("new", 3)
+	rollback()
+	expect("good", 0)
+
+	versions, err := store.List("dhcpd")
+	if err != nil {
+		t.Fatalf("failed to list versions: %v", err)
+	}
+	if last := versions[len(versions)-1]; last.Version != 5 || last.RollbackOf != 1 {
+		t.Errorf("expected version 5 to be a rollback of version 1 but got %+v", last)
+	}
+}
+
+// Test that hooks still run when the applied files can't be saved and that
+// the error is returned.
+func TestStateApplyRunsHooksWhenSaveFails(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		store  = state.NewStore(filepath.Join(dir, "state"), 0)
+		path   = filepath.Join(dir, "conman.conf")
+		ran    = filepath.Join(dir, "ran")
+		target = configurator.Target{Hooks: []string{"touch " + ran}}
+	)
+
+	// a file where a version directory is saved makes saving fail
+	if err := os.MkdirAll(filepath.Join(dir, "state", "conman"), 0o700); err != nil {
+		t.Fatalf("failed to make state directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "state", "conman", "1"), []byte{}, 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	_, err := store.Apply(state.Meta{Target: "conman"}, target, map[string][]byte{path: []byte("contents")})
+	if err == nil {
+		t.Error("expected an error saving the applied files")
+	}
+	if _, err := os.Stat(ran); err != nil {
+		t.Errorf("expected hooks to run when saving fails: %v", err)
+	}
+}
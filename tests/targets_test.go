@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/client/smdtest"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/server"
+)
+
+// Test creating, replacing, and removing targets with the API and that the
+// targets are loaded again when the server restarts.
+func TestTargetsAPI(t *testing.T) {
+	var (
+		conf = config.New()
+		smd  = smdtest.NewServer(smdtest.DefaultFixtures())
+		// "{{ plugin_name }}" and "# {{ plugin_name }}" encoded as base64
+		target   = `{"name": "custom", "plugin": "dnsmasq", "templates": [{"contents": "e3sgcGx1Z2luX25hbWUgfX0="}]}`
+		replaced = `{"plugin": "dnsmasq", "templates": [{"contents": "IyB7eyBwbHVnaW5fbmFtZSB9fQ=="}]}`
+	)
+	defer smd.Close()
+	conf.SmdClient.Host = smd.URL
+	conf.Server.Storage = t.TempDir()
+	conf.Targets["config"] = configurator.Target{Plugin: "dnsmasq"}
+	s := httptest.NewServer(server.New(&conf).NewRouter())
+	defer s.Close()
+
+	var tests = []struct {
+		method   string
+		path     string
+		body     string
+		status   int
+		expected string
+	}{
+		{http.MethodPost, "/targets", target, http.StatusCreated, `"name":"custom"`},
+		{http.MethodPost, "/targets", target, http.StatusConflict, "already exists"},
+		{http.MethodPost, "/targets", `{"name": "bad", "plugin": "dnsmasq"}`, http.StatusBadRequest, "at least one template"},
+		{http.MethodPost, "/targets", `{"name": "custom"`, http.StatusBadRequest, "failed to unmarshal"},
+		{http.MethodGet, "/targets/custom", "", http.StatusOK, `"plugin":"dnsmasq"`},
+		{http.MethodGet, "/targets/missing", "", http.StatusNotFound, "not found"},
+		{http.MethodGet, "/targets", "", http.StatusOK, `"name":"dnsmasq"`},
+		{http.MethodGet, "/generate?target=custom", "", http.StatusOK, `"custom_0":"dnsmasq"`},
+		{http.MethodPut, "/targets/custom", replaced, http.StatusOK, `"name":"custom"`},
+		{http.MethodGet, "/generate?target=custom", "", http.StatusOK, `"custom_0":"# dnsmasq"`},
+		{http.MethodPut, "/targets/custom", `{"name": "other", "plugin": "dnsmasq"}`, http.StatusBadRequest, "does not match"},
+		{http.MethodPut, "/targets/config", replaced, http.StatusConflict, "set in the config"},
+		{http.MethodPut, "/targets/new", replaced, http.StatusCreated, `"name":"new"`},
+		{http.MethodDelete, "/targets/new", "", http.StatusNoContent, ""},
+		{http.MethodDelete, "/targets/new", "", http.StatusNotFound, "not found"},
+		{http.MethodDelete, "/targets/dnsmasq", "", http.StatusConflict, "set in the config"},
+	}
+	for _, test := range tests {
+		status, body, err := sendRequest(test.method, s.URL+test.path, test.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != test.status || !strings.Contains(body, test.expected) {
+			t.Errorf("expected %s %s to return %d with '%s' but got %d: %s", test.method, test.path, test.status, test.expected, status, body)
+		}
+	}
+
+	// targets created with the API are loaded again after a restart
+	restarted := httptest.NewServer(server.New(&conf).NewRouter())
+	defer restarted.Close()
+	if status, body, err := sendRequest(http.MethodGet, restarted.URL+"/generate?target=custom", ""); err != nil || status != http.StatusOK || !strings.Contains(body, "# dnsmasq") {
+		t.Errorf("expected the replaced target to be loaded after restarting but got %d: %s (%v)", status, body, err)
+	}
+	if status, _, err := sendRequest(http.MethodGet, restarted.URL+"/targets/new", ""); err != nil || status != http.StatusNotFound {
+		t.Errorf("expected the removed target to not be loaded after restarting but got %d (%v)", status, err)
+	}
+}
+
+// Test that a config target that fails to load its templates is skipped and
+// that the default generator target it overrides is kept.
+func TestTargetsWithMissingTemplates(t *testing.T) {
+	var (
+		conf    = config.New()
+		missing = filepath.Join(t.TempDir(), "missing.jinja")
+	)
+	conf.Server.Storage = t.TempDir()
+	conf.Targets["dnsmasq"] = configurator.Target{TemplatePaths: []string{missing}}
+	conf.Targets["broken"] = configurator.Target{Plugin: "dnsmasq", TemplatePaths: []string{missing}}
+	s := httptest.NewServer(server.New(&conf).NewRouter())
+	defer s.Close()
+
+	if status, body, err := sendRequest(http.MethodGet, s.URL+"/targets/dnsmasq", ""); err != nil || status != http.StatusOK || !strings.Contains(body, `"plugin":"dnsmasq"`) {
+		t.Errorf("expected the default dnsmasq target to be kept but got %d: %s (%v)", status, body, err)
+	}
+	if status, body, err := sendRequest(http.MethodGet, s.URL+"/targets/broken", ""); err != nil || status != http.StatusNotFound {
+		t.Errorf("expected the target that failed to load to be skipped but got %d: %s (%v)", status, body, err)
+	}
+}
+
+// Sends a request with the body and returns the status code and response
+// body. Errors are returned instead of failing the test so that it can be
+// called from other goroutines.
+func sendRequest(method string, url string, body string) (int, string, error) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to make request: %v", err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to send request: %v", err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read response: %v", err)
+	}
+	return res.StatusCode, string(b), nil
+}
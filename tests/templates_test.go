@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/OpenCHAMI/configurator/pkg/client/smdtest"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/server"
+	"github.com/OpenCHAMI/configurator/pkg/storage"
+)
+
+// Test that templates are stored with a new version only when they change
+// and that every version can be read back.
+func TestFileSystemStorage(t *testing.T) {
+	fs := storage.NewFileSystem(t.TempDir())
+
+	first, err := fs.PutTemplate(storage.Template{Name: "dnsmasq.jinja", Contents: "{{ dhcp_hosts }}"})
+	if err != nil {
+		t.Fatalf("failed to put template: %v", err)
+	}
+	same, err := fs.PutTemplate(storage.Template{Name: "dnsmasq.jinja", Contents: "{{ dhcp_hosts }}"})
+	if err != nil || same.Version != 1 || same.Hash != first.Hash {
+		t.Errorf("expected the unchanged template to stay at version 1 but got %d (%v)", same.Version, err)
+	}
+	second, err := fs.PutTemplate(storage.Template{Name: "dnsmasq.jinja", Contents: "# dnsmasq\n{{ dhcp_hosts }}"})
+	if err != nil || second.Version != 2 || second.Hash == first.Hash {
+		t.Errorf("expected the changed template to be version 2 with a new hash but got %d (%v)", second.Version, err)
+	}
+
+	template, err := fs.GetTemplate("dnsmasq.jinja", 1)
+	if err != nil || template.Contents != "{{ dhcp_hosts }}" {
+		t.Errorf("expected the contents of version 1 but got '%s' (%v)", template.Contents, err)
+	}
+	template, err = fs.GetTemplate("dnsmasq.jinja", 0)
+	if err != nil || template.Version != 2 {
+		t.Errorf("expected the latest version to be 2 but got %d (%v)", template.Version, err)
+	}
+	versions, err := fs.TemplateVersions("dnsmasq.jinja")
+	if err != nil || len(versions) != 2 || versions[0].Contents != "" {
+		t.Errorf("expected 2 versions without contents but got %+v (%v)", versions, err)
+	}
+	templates, err := fs.ListTemplates()
+	if err != nil || len(templates) != 1 || templates[0].Version != 2 {
+		t.Errorf("expected the latest version of 1 template but got %+v (%v)", templates, err)
+	}
+
+	if err := fs.DeleteTemplate("dnsmasq.jinja"); err != nil {
+		t.Fatalf("failed to delete template: %v", err)
+	}
+	if _, err := fs.GetTemplate("dnsmasq.jinja", 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the deleted template to not be found but got: %v", err)
+	}
+	if _, err := fs.PutTemplate(storage.Template{Name: "../dnsmasq.jinja"}); !errors.Is(err, storage.ErrInvalidName) {
+		t.Errorf("expected an error for a name that is a path but got: %v", err)
+	}
+
+	// templates are only created if they don't already exist
+	created, err := fs.CreateTemplate(storage.Template{Name: "dnsmasq.jinja", Contents: "{{ dhcp_hosts }}"})
+	if err != nil || created.Version != 1 || created.Hash != first.Hash {
+		t.Errorf("expected the created template to be version 1 but got %d (%v)", created.Version, err)
+	}
+	if _, err := fs.CreateTemplate(storage.Template{Name: "dnsmasq.jinja", Contents: "# dnsmasq"}); !errors.Is(err, storage.ErrExists) {
+		t.Errorf("expected an error creating a template that exists but got: %v", err)
+	}
+}
+
+// Test creating, updating, and removing templates with the API and that
+// targets use the latest version of the templates they reference by name.
+func TestTemplatesAPI(t *testing.T) {
+	var (
+		conf = config.New()
+		smd  = smdtest.NewServer(smdtest.DefaultFixtures())
+	)
+	defer smd.Close()
+	conf.SmdClient.Host = smd.URL
+	conf.Server.Storage = t.TempDir()
+	s := httptest.NewServer(server.New(&conf).NewRouter())
+	defer s.Close()
+
+	var tests = []struct {
+		method   string
+		path     string
+		body     string
+		status   int
+		expected string
+	}{
+		{http.MethodPost, "/templates", `{"name": "dnsmasq.jinja", "contents": "{{ plugin_name }}"}`, http.StatusCreated, `"variables":["plugin_name"]`},
+		{http.MethodPost, "/templates", `{"name": "dnsmasq.jinja", "contents": "{{ plugin_name }}"}`, http.StatusConflict, "already exists"},
+		{http.MethodPost, "/templates", `{"name": "bad.jinja", "contents": "{% if x %}"}`, http.StatusBadRequest, "invalid template"},
+		{http.MethodPost, "/templates", `{"name": "bad.tmpl", "contents": "{{ .x | nope }}"}`, http.StatusBadRequest, "invalid template"},
+		{http.MethodPut, "/templates/dnsmasq.jinja", `{"contents": "# {{ plugin_name }}"}`, http.StatusOK, `"version":2`},
+		{http.MethodGet, "/templates/dnsmasq.jinja?version=1", "", http.StatusOK, `"contents":"{{ plugin_name }}"`},
+		{http.MethodGet, "/templates/dnsmasq.jinja/versions", "", http.StatusOK, `"version":2`},
+		{http.MethodGet, "/templates", "", http.StatusOK, `"engine":"jinja"`},
+		{http.MethodGet, "/templates/missing.jinja", "", http.StatusNotFound, "not found"},
+		{http.MethodPost, "/targets", `{"name": "test", "plugin": "dnsmasq", "template_refs": [{"name": "missing.jinja"}]}`, http.StatusNotFound, "missing.jinja"},
+		{http.MethodPost, "/targets", `{"name": "test", "plugin": "dnsmasq", "template_refs": [{"name": "dnsmasq.jinja"}]}`, http.StatusCreated, ""},
+		{http.MethodGet, "/generate?target=test", "", http.StatusOK, `"dnsmasq.jinja":"# dnsmasq"`},
+		{http.MethodDelete, "/templates/dnsmasq.jinja", "", http.StatusConflict, "used by target 'test'"},
+		{http.MethodDelete, "/templates/missing.jinja", "", http.StatusNotFound, "not found"},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, s.URL+test.path, strings.NewReader(test.body))
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		b, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != test.status || !strings.Contains(string(b), test.expected) {
+			t.Errorf("expected %s %s to return %d with '%s' but got %d: %s", test.method, test.path, test.status, test.expected, res.StatusCode, string(b))
+		}
+	}
+
+	// templates not used by a target can be removed
+	req, _ := http.NewRequest(http.MethodPut, s.URL+"/templates/unused.tmpl", strings.NewReader(`{"contents": "{{ .x }}"}`))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	var created map[string]any
+	json.NewDecoder(res.Body).Decode(&created)
+	res.Body.Close()
+	if res.StatusCode != http.StatusCreated || created["engine"] != "gotmpl" {
+		t.Errorf("expected the template to be created with the Go template engine but got %d: %v", res.StatusCode, created)
+	}
+	req, _ = http.NewRequest(http.MethodDelete, s.URL+"/templates/unused.tmpl", nil)
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("expected the template to be removed but got %d", res.StatusCode)
+	}
+}
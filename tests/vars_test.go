@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	configurator "github.com/OpenCHAMI/configurator/pkg"
+	"github.com/OpenCHAMI/configurator/pkg/client/smdtest"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+)
+
+// Test that variables from the config and CLI are passed to templates with
+// generator defaults < global vars < target vars < CLI vars.
+func TestTemplateVarsPrecedence(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		path = filepath.Join(dir, "dnsmasq.jinja")
+		conf = config.New()
+		s    = smdtest.NewServer(smdtest.DefaultFixtures())
+	)
+	defer s.Close()
+
+	err := os.WriteFile(path, []byte("{{ plugin_name }} {{ domain }} {{ subnet }} {{ boot_server }}"), 0o644)
+	if err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	conf.SmdClient.Host = s.URL
+	conf.Vars = map[string]any{
+		"plugin_name": "global",
+		"domain":      "global",
+		"subnet":      "global",
+		"boot_server": "global",
+	}
+	conf.Targets["dnsmasq"] = configurator.Target{
+		TemplatePaths: []string{path},
+		Vars: map[string]any{
+			"subnet":      "target",
+			"boot_server": "target",
+		},
+	}
+
+	outputs, err := generator.GenerateWithTarget(&conf, "dnsmasq",
+		generator.WithVars(map[string]any{"boot_server": "cli"}),
+	)
+	if err != nil {
+		t.Fatalf("failed to generate with target: %v", err)
+	}
+	expected := "global global target cli"
+	if string(outputs[path]) != expected {
+		t.Errorf("expected '%s' but got '%s'", expected, string(outputs[path]))
+	}
+
+	// the variables are also provided when linting
+	provides, _, err := generator.LintTarget(&conf, "dnsmasq")
+	if err != nil {
+		t.Fatalf("failed to lint target: %v", err)
+	}
+	if !strings.Contains(strings.Join(provides, ","), "domain,") {
+		t.Errorf("expected 'domain' to be provided but got %v", provides)
+	}
+}
+
+// Test that nested variables loaded from the config can be used in templates
+// and marshalled to JSON.
+func TestLoadNestedVars(t *testing.T) {
+	var (
+		path = filepath.Join(t.TempDir(), "config.yaml")
+		data = strings.Join([]string{
+			"vars:",
+			"  dns:",
+			"    servers: [172.16.0.253, 172.16.0.254]",
+			"    options: {ndots: 2}",
+			"targets:",
+			"  dnsmasq:",
+			"    vars:",
+			"      domain: openchami.cluster",
+		}, "\n")
+	)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	conf := config.Load(path)
+	vars := conf.TemplateVars("dnsmasq")
+
+	if _, err := json.Marshal(vars); err != nil {
+		t.Errorf("failed to marshal vars: %v", err)
+	}
+	outputs, err := generator.ApplyTemplates(vars, map[string]generator.Template{
+		"test": {Contents: []byte("{{ dns.servers[1] }} {{ dns.options.ndots }} {{ domain }}"), Strict: true},
+	})
+	if err != nil {
+		t.Fatalf("failed to apply template: %v", err)
+	}
+	expected := "172.16.0.254 2 openchami.cluster"
+	if string(outputs["test"]) != expected {
+		t.Errorf("expected '%s' but got '%s'", expected, string(outputs["test"]))
+	}
+}
+
+// A generator like an external plugin that applies templates with its own
+// mappings without merging the variables itself.
+type varsPluginGenerator struct{}
+
+func (g *varsPluginGenerator) GetName() string    { return "vars-plugin" }
+func (g *varsPluginGenerator) GetVersion() string { return "v1.0.0" }
+func (g *varsPluginGenerator) GetDescription() string {
+	return "applies templates without merging vars"
+}
+func (g *varsPluginGenerator) Generate(config *config.Config, params generator.Params) (generator.FileMap, error) {
+	return generator.ApplyTemplates(generator.Mappings{
+		"plugin_name": g.GetName(),
+		"domain":      "plugin",
+	}, params.Templates)
+}
+
+// Test that variables from the config and CLI override the mappings of
+// generators that don't merge them like external plugins.
+func TestTemplateVarsWithPlugin(t *testing.T) {
+	var (
+		path = filepath.Join(t.TempDir(), "plugin.jinja")
+		conf = config.New()
+		gen  = &varsPluginGenerator{}
+	)
+	generator.DefaultGenerators[gen.GetName()] = gen
+	defer delete(generator.DefaultGenerators, gen.GetName())
+
+	err := os.WriteFile(path, []byte("{{ plugin_name }} {{ domain }} {{ subnet }}"), 0o644)
+	if err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	conf.Vars = map[string]any{"domain": "global"}
+	conf.Targets[gen.GetName()] = configurator.Target{
+		TemplatePaths: []string{path},
+		Vars:          map[string]any{"subnet": "target"},
+	}
+
+	outputs, err := generator.GenerateWithTarget(&conf, gen.GetName(),
+		generator.WithVars(map[string]any{"subnet": "cli"}),
+	)
+	if err != nil {
+		t.Fatalf("failed to generate with target: %v", err)
+	}
+	if expected := "vars-plugin global cli"; string(outputs[path]) != expected {
+		t.Errorf("expected '%s' but got '%s'", expected, string(outputs[path]))
+	}
+
+	// the variables are also used when generating with a plugin by name
+	outputs, err = generator.Generate(&conf, gen.GetName(), generator.Params{
+		Templates: map[string]generator.Template{"test": {Contents: []byte("{{ domain }}")}},
+		Vars:      conf.TemplateVars(gen.GetName()),
+	})
+	if err != nil {
+		t.Fatalf("failed to generate with plugin: %v", err)
+	}
+	if string(outputs["test"]) != "global" {
+		t.Errorf("expected 'global' but got '%s'", string(outputs["test"]))
+	}
+}
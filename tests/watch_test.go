@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/configurator/pkg/client"
+	"github.com/OpenCHAMI/configurator/pkg/client/smdtest"
+	"github.com/OpenCHAMI/configurator/pkg/config"
+	"github.com/OpenCHAMI/configurator/pkg/generator"
+	"github.com/OpenCHAMI/configurator/pkg/watch"
+)
+
+// Test that notifications received close together only cause a single run
+// and that failed runs are retried.
+func TestWatchDebounceAndBackoff(t *testing.T) {
+	var (
+		ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+		reasons     = make(chan string, 10)
+		fail        = true
+	)
+	defer cancel()
+
+	watcher := watch.NewWatcher(func(ctx context.Context, reason string) error {
+		reasons <- reason
+		if fail {
+			fail = false
+			return errors.New("failed to fetch inventory")
+		}
+		return nil
+	},
+		watch.WithInterval(0),
+		watch.WithDebounce(50*time.Millisecond),
+		watch.WithBackoff(10*time.Millisecond, 10*time.Millisecond),
+	)
+	go watcher.Start(ctx)
+
+	// the first run fails and should be retried after the backoff
+	for _, expected := range []string{"startup", "retry"} {
+		if reason := <-reasons; reason != expected {
+			t.Fatalf("expected run for '%s' but got '%s'", expected, reason)
+		}
+	}
+
+	// send a burst of notifications through the SCN handler
+	handler := watcher.Handler()
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/scn", strings.NewReader(`{"Components":["x1000c0s0b0n0"],"State":"Ready"}`)))
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("expected status %d but got %d", http.StatusAccepted, rec.Code)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if reason := <-reasons; reason != "scn" {
+		t.Fatalf("expected run for 'scn' but got '%s'", reason)
+	}
+	select {
+	case reason := <-reasons:
+		t.Errorf("expected notifications to be debounced but got another run for '%s'", reason)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// Test that each generator sharing a cache gets a hash of only the inventory
+// that it used, which changes when that inventory changes.
+func TestCacheTrackHash(t *testing.T) {
+	var (
+		conf     = config.New()
+		fixtures = smdtest.DefaultFixtures()
+		s        = smdtest.NewServer(fixtures)
+		hash     = func(cache *client.Cache, name string) string {
+			tracked := cache.Track()
+			_, err := generator.DefaultGenerators[name].Generate(&conf, fakeSmdParams(s, "", client.WithCache(tracked)))
+			if err != nil {
+				t.Fatalf("failed to generate with '%s': %v", name, err)
+			}
+			return tracked.Hash()
+		}
+	)
+	defer s.Close()
+
+	cache := client.NewCache(0)
+	dnsmasq, conman := hash(cache, "dnsmasq"), hash(cache, "conman")
+	if dnsmasq == conman {
+		t.Error("expected generators using different inventory to have different hashes")
+	}
+	if hash(cache, "dnsmasq") != dnsmasq {
+		t.Error("expected the same hash when the inventory has not changed")
+	}
+
+	// only the generator using the changed inventory should get a new hash
+	fixtures.EthernetInterfaces = fixtures.EthernetInterfaces[:1]
+	s.SetFixtures(fixtures)
+	cache = client.NewCache(0)
+	if hash(cache, "dnsmasq") == dnsmasq {
+		t.Error("expected the hash to change when the inventory changed")
+	}
+	if hash(cache, "conman") != conman {
+		t.Error("expected the hash to stay the same for inventory that did not change")
+	}
+}
+
+// Test that refreshing a shared cache fetches the inventory used by each
+// generator again so that a changed hash can be found without generating.
+func TestCacheRefreshHash(t *testing.T) {
+	var (
+		conf     = config.New()
+		fixtures = smdtest.DefaultFixtures()
+		s        = smdtest.NewServer(fixtures)
+		cache    = client.NewCache(0)
+		tracked  = map[string]*client.Cache{}
+		hashes   = map[string]string{}
+	)
+	defer s.Close()
+
+	for _, name := range []string{"dnsmasq", "conman"} {
+		tracked[name] = cache.Track()
+		_, err := generator.DefaultGenerators[name].Generate(&conf, fakeSmdParams(s, "", client.WithCache(tracked[name])))
+		if err != nil {
+			t.Fatalf("failed to generate with '%s': %v", name, err)
+		}
+		hashes[name] = tracked[name].Hash()
+	}
+
+	// nothing changed so the hashes stay the same after refreshing
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatalf("failed to refresh cache: %v", err)
+	}
+	if s.Requests("/hsm/v2/Inventory/EthernetInterfaces") != 2 {
+		t.Errorf("expected interfaces to be fetched again but got %d requests", s.Requests("/hsm/v2/Inventory/EthernetInterfaces"))
+	}
+	for name, hash := range hashes {
+		if tracked[name].Hash() != hash {
+			t.Errorf("expected the hash for '%s' to stay the same", name)
+		}
+	}
+
+	// only the hash for the generator using the changed inventory changes
+	fixtures.EthernetInterfaces = fixtures.EthernetInterfaces[:1]
+	s.SetFixtures(fixtures)
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatalf("failed to refresh cache: %v", err)
+	}
+	if tracked["dnsmasq"].Hash() == hashes["dnsmasq"] {
+		t.Error("expected the hash to change when the inventory changed")
+	}
+	if tracked["conman"].Hash() != hashes["conman"] {
+		t.Error("expected the hash to stay the same for inventory that did not change")
+	}
+
+	// errors are returned instead of keeping the old inventory
+	s.SetError("/hsm/v2/Inventory/RedfishEndpoints", http.StatusServiceUnavailable)
+	if err := cache.Refresh(context.Background()); err == nil {
+		t.Error("expected an error refreshing the cache")
+	}
+}
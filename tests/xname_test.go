@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/OpenCHAMI/configurator/pkg/xname"
+)
+
+// Test that xnames are parsed into their parts and normalized when converted
+// back to strings.
+func TestParseXName(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected xname.XName
+		str      string
+	}{
+		{"x1000", xname.NewCabinet(1000), "x1000"},
+		{"x1000c0", xname.NewChassis(1000, 0), "x1000c0"},
+		{"x1000c0s7", xname.NewSlot(1000, 0, 7), "x1000c0s7"},
+		{"x1000c0s7b0", xname.NewBMC(1000, 0, 7, 0), "x1000c0s7b0"},
+		{"x1000c0s7b0n1", xname.NewNode(1000, 0, 7, 0, 1), "x1000c0s7b0n1"},
+		{" X01000C0S07B0N1 ", xname.NewNode(1000, 0, 7, 0, 1), "x1000c0s7b0n1"},
+	}
+	for _, test := range tests {
+		x, err := xname.Parse(test.input)
+		if err != nil {
+			t.Errorf("failed to parse '%s': %v", test.input, err)
+			continue
+		}
+		if x != test.expected {
+			t.Errorf("expected '%s' to parse to %+v but got %+v", test.input, test.expected, x)
+		}
+		if x.String() != test.str {
+			t.Errorf("expected '%s' but got '%s'", test.str, x.String())
+		}
+	}
+
+	for _, input := range []string{"", "x", "c0s0", "x1000s0", "x1000c0s0n0", "x1000c0s0b0n0p0", "x-1", "node01"} {
+		if xname.IsValid(input) {
+			t.Errorf("expected '%s' to be invalid", input)
+		}
+	}
+}
+
+// Test walking the physical hierarchy up and down from an xname.
+func TestXNameRelationships(t *testing.T) {
+	node := xname.NewNode(1000, 0, 7, 0, 1)
+
+	// walk up from the node to the cabinet
+	var (
+		parents  = []string{}
+		expected = []string{"x1000c0s7b0", "x1000c0s7", "x1000c0", "x1000"}
+	)
+	for x, ok := node.Parent(); ok; x, ok = x.Parent() {
+		parents = append(parents, x.String())
+	}
+	if !slices.Equal(parents, expected) {
+		t.Errorf("expected parents %v but got %v", expected, parents)
+	}
+
+	if cabinet, ok := node.Ancestor(xname.Cabinet); !ok || cabinet != xname.NewCabinet(1000) {
+		t.Errorf("expected cabinet 'x1000' but got '%s'", cabinet)
+	}
+	if _, ok := xname.NewSlot(1000, 0, 7).Ancestor(xname.BMC); ok {
+		t.Error("expected a slot to not have a BMC ancestor")
+	}
+
+	// walk back down from the slot to the node
+	bmc, err := xname.NewSlot(1000, 0, 7).Child(0)
+	if err != nil || bmc != xname.NewBMC(1000, 0, 7, 0) {
+		t.Errorf("expected BMC 'x1000c0s7b0' but got '%s' (%v)", bmc, err)
+	}
+	child, err := bmc.Child(1)
+	if err != nil || child != node {
+		t.Errorf("expected node '%s' but got '%s' (%v)", node, child, err)
+	}
+	if _, err := node.Child(0); err == nil {
+		t.Error("expected a node to not have children")
+	}
+
+	if !xname.NewCabinet(1000).Contains(node) || !bmc.Contains(node) {
+		t.Error("expected the cabinet and BMC to contain the node")
+	}
+	if node.Contains(node) || xname.NewCabinet(1001).Contains(node) || node.Contains(bmc) {
+		t.Error("expected the node to not be contained")
+	}
+}
+
+// Test that xnames are sorted in physical order instead of lexically.
+func TestSortXNames(t *testing.T) {
+	var (
+		names = []string{
+			"x1000c0s10b0n0",
+			"not-an-xname",
+			"x1000c0s2b0n1",
+			"x1000c0s2b0",
+			"x1000c0s2b0n0",
+			"x9c0s0b0n0",
+			"x1000",
+		}
+		expected = []string{
+			"x9c0s0b0n0",
+			"x1000",
+			"x1000c0s2b0",
+			"x1000c0s2b0n0",
+			"x1000c0s2b0n1",
+			"x1000c0s10b0n0",
+			"not-an-xname",
+		}
+	)
+	xname.SortStrings(names)
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %v but got %v", expected, names)
+	}
+
+	xnames := []xname.XName{xname.NewNode(1, 0, 1, 0, 0), xname.NewBMC(1, 0, 1, 0), xname.NewCabinet(1)}
+	xname.Sort(xnames)
+	if xnames[0] != xname.NewCabinet(1) || xnames[2] != xname.NewNode(1, 0, 1, 0, 0) {
+		t.Errorf("expected parents to be sorted before children but got %v", xnames)
+	}
+}